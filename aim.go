@@ -0,0 +1,90 @@
+package main
+
+import (
+	"math"
+
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
+	"golang.org/x/image/colornames"
+)
+
+// predictStep is the time step of the trajectory simulation.
+const predictStep = 1.0 / 60
+
+// TrajectoryPoint is one sample of the predicted flight of an arrow.
+type TrajectoryPoint struct {
+	Pos     pixel.Vec
+	Height  float64
+	CanKill bool
+}
+
+// PredictFlight simulates the flight of arrow a as if it was shot now and
+// returns sampled points of the trajectory, the last one is the landing spot.
+// The arrow itself is not modified.
+func PredictFlight(a *Arrow, from, to, relational pixel.Vec, buf []TrajectoryPoint) []TrajectoryPoint {
+	p := *a
+	p.Fly(from, to, relational)
+	buf = buf[:0]
+	// Limit the number of steps in case the arrow never reaches the target.
+	for i := 0; i < 600; i++ {
+		buf = append(buf, TrajectoryPoint{p.Pos, p.CurrentHeight(), p.CanKill()})
+		if p.move(predictStep) {
+			break
+		}
+	}
+	buf = append(buf, TrajectoryPoint{p.Pos, 0, true})
+	return buf
+}
+
+// DrawTrajectory draws the arc of predicted flight. The height of the arrow is
+// shown as the offset above its shadow on the ground, the parts where the arrow
+// kills are drawn in red and the landing spot is marked with a circle.
+func DrawTrajectory(imd *imdraw.IMDraw, points []TrajectoryPoint) {
+	if len(points) == 0 {
+		return
+	}
+	for i, p := range points {
+		if i%3 != 0 {
+			continue
+		}
+		imd.Color = pixel.ToRGBA(colornames.Darkslategray).Scaled(0.6)
+		imd.Push(p.Pos)
+		imd.Circle(0.5, 0)
+		if p.CanKill {
+			imd.Color = colornames.Red
+		} else {
+			imd.Color = colornames.Lightyellow
+		}
+		imd.Push(p.Pos.Add(pixel.V(0, p.Height/4)))
+		imd.Circle(0.6, 0)
+	}
+	imd.Color = colornames.Red
+	imd.Push(points[len(points)-1].Pos)
+	imd.Circle(3, 0.6)
+}
+
+// AimAssist nudges target towards the living enemy closest to it within
+// radius, if the enemy is inside the cone of half-angle cone around the aiming
+// direction. Strength is in range 0 ... 1, where 1 aims straight at the enemy.
+func AimAssist(from, target pixel.Vec, horde *Horde, radius, cone, strength float64) pixel.Vec {
+	aim := target.Sub(from)
+	if aim.Len() == 0 {
+		return target
+	}
+	best := horde.Nearest(target, radius, func(e Enemy) bool {
+		return angleBetween(aim, e.Body().Pos.Sub(from)) <= cone
+	})
+	if best == nil {
+		return target
+	}
+	return pixel.Lerp(target, best.Body().Pos, strength)
+}
+
+// angleBetween returns the absolute angle between vectors a and b in range 0 ... Pi.
+func angleBetween(a, b pixel.Vec) float64 {
+	d := math.Abs(a.Angle() - b.Angle())
+	if d > math.Pi {
+		d = 2*math.Pi - d
+	}
+	return d
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"image/color"
 	"math"
+	"math/rand"
 
 	"github.com/faiface/pixel"
 	"golang.org/x/image/colornames"
@@ -15,12 +17,25 @@ type Arrow struct {
 	target       pixel.Vec // where the arrow should drop down
 	halfDistance float64   // half the distance from original spawn point to the target
 	maxHeight    float64
+	breakChance  float64 // chance to break when the arrow hits something
+	Pierce       int     // how many slimes the arrow passes through before it sticks
+	pierceLeft   int
+	ricochetLeft int     // times the arrow bounces to the next enemy instead of sticking
+	bouncing     bool    // the arrow waits for the horde to pick the next enemy
+	kills        int     // slimes killed during the current flight
+	Damage       float64 // damage dealt to enemies which take more than one hit
+	Speed        float64
 	State        ArrowState
+	Type         ArrowType
+	stuckAt      float64 // when the arrow stuck in the ground
 }
 
 // Arrow starts this far from the center of the hero.
 const ArrowStartDistance = 10.0
 
+// Part of the hero velocity which is added to the velocity of the shot arrow.
+const HeroVelocityTransfer = 0.22
+
 type ArrowState uint8
 
 const (
@@ -31,18 +46,67 @@ const (
 	ArrowStuck
 )
 
+// ArrowType defines the material of the arrow. Types with bigger values are
+// drawn from the quiver first.
+type ArrowType uint8
+
+const (
+	ArrowWooden ArrowType = iota
+	ArrowSteel
+	ArrowFire
+)
+
+var arrowTypeColors = [...]color.RGBA{
+	ArrowWooden: colornames.Goldenrod,
+	ArrowSteel:  colornames.Lightsteelblue,
+	ArrowFire:   colornames.Orangered,
+}
+
+// arrowTypePierce is the number of slimes the arrow of each type goes through.
+var arrowTypePierce = [...]int{
+	ArrowWooden: 0,
+	ArrowSteel:  1,
+	ArrowFire:   0,
+}
+
+// arrowTypeDurability scales the break chance of the quiver per arrow type.
+var arrowTypeDurability = [...]float64{
+	ArrowWooden: 1,
+	ArrowSteel:  0.25,
+	ArrowFire:   2,
+}
+
+// arrowTypeBurn is the damage per second dealt by burning for arrowBurnTime
+// to enemies hit by the arrow of each type.
+var arrowTypeBurn = [...]float64{
+	ArrowWooden: 0,
+	ArrowSteel:  0,
+	ArrowFire:   0.6,
+}
+
+const arrowBurnTime = 3.0
+
 func NewArrow(normal, stuck *pixel.Sprite) *Arrow {
 	a := &Arrow{Entity: *NewEntity(normal, pixel.ZV)}
 	a.StuckSprite = stuck
 	a.Deactivate()
 	a.State = ArrowInactive
 	a.baseScale = 1
+	a.Damage = 1
+	a.Speed = tuning.Arrow.Speed
 	a.Color = colornames.Goldenrod
 	r := pixel.R(-1, -1, 1, 1)
 	a.Collider = &r
 	return a
 }
 
+func (a *Arrow) SetType(t ArrowType, breakChance float64) {
+	a.Type = t
+	a.Color = arrowTypeColors[t]
+	a.breakChance = breakChance * arrowTypeDurability[t]
+	a.Pierce = arrowTypePierce[t]
+}
+
 func (a *Arrow) DistanceToTarget() float64 {
 	return a.Pos.Sub(a.target).Len()
 }
@@ -81,8 +145,14 @@ func (a *Arrow) AttachToHands(from, to pixel.Vec) {
 	a.ScaleXY.Y = 1.0
 }
 
-func (a *Arrow) AttachToQuiver(pos pixel.Vec, idx int) {
-	a.Pos = pos.Add(pixel.V(-8+3*float64(idx), 7))
+// AttachToQuiver puts the arrow idx out of n arrows behind the back of the hero.
+// Arrows are packed tighter when there are many of them.
+func (a *Arrow) AttachToQuiver(pos pixel.Vec, idx, n int) {
+	step := 3.0
+	if n > 1 {
+		step = math.Min(step, 12/float64(n-1))
+	}
+	a.Pos = pos.Add(pixel.V(-8+step*float64(idx), 7))
 	a.Angle = math.Pi / 2
 	a.ScaleXY.X = 0.5
 	a.ScaleXY.Y = 0.5
@@ -93,33 +163,99 @@ func (a *Arrow) Fly(from, to, relational pixel.Vec) {
 	dir := to.Sub(from).Unit()
 	a.Pos = from.Add(dir.Scaled(ArrowStartDistance))
 	a.Angle = dir.Angle()
-	a.vel = dir.Scaled(150).Add(relational)
+	a.vel = dir.Scaled(a.Speed).Add(relational)
 	a.target = to
+	a.pierceLeft = a.Pierce
+	a.ricochetLeft = 0
+	a.bouncing = false
+	a.kills = 0
 	a.halfDistance = a.Pos.Sub(a.target).Len() / 2
 	// height takes values in range [0, 50]
-	a.maxHeight = pixel.Clamp(a.halfDistance/1.2, 0, 100)
+	a.maxHeight = pixel.Clamp(a.halfDistance/1.2, 0, tuning.Arrow.MaxHeight)
 	// fmt.Println(a.halfDistance, a.maxHeight)
 }
 
+func (a *Arrow) Velocity() pixel.Vec {
+	return a.vel
+}
+
 func (a *Arrow) Kills(col pixel.Rect) bool {
 	return a.State == ArrowFlying && a.CanKill() && collides(col, a.AbsCollider())
 }
 
+// Hit registers a kill. The arrow sticks unless it can pierce through more
+// slimes or ricochet to another one.
+func (a *Arrow) Hit() {
+	a.kills++
+	if a.pierceLeft > 0 {
+		a.pierceLeft--
+		return
+	}
+	if a.ricochetLeft > 0 {
+		a.ricochetLeft--
+		a.bouncing = true
+		return
+	}
+	a.Stick()
+}
+
+// Ricochet turns the flying arrow from where it is towards to.
+func (a *Arrow) Ricochet(to pixel.Vec) {
+	dir := to.Sub(a.Pos).Unit()
+	a.Angle = dir.Angle()
+	a.vel = dir.Scaled(a.Speed)
+	a.target = to
+	a.halfDistance = a.Pos.Sub(to).Len() / 2
+	a.maxHeight = pixel.Clamp(a.halfDistance/1.2, 0, tuning.Arrow.MaxHeight)
+}
+
+// Afflict applies the effects of the arrow type to the status of the enemy it hit.
+func (a *Arrow) Afflict(s *Statuses) {
+	if burn := arrowTypeBurn[a.Type]; burn > 0 {
+		s.Apply(StatusBurn, burn, arrowBurnTime)
+	}
+}
+
+// KillCount returns the number of slimes killed by the arrow since it was shot.
+func (a *Arrow) KillCount() int {
+	return a.kills
+}
+
+// Stick leaves the arrow in the ground, unless it breaks on impact.
 func (a *Arrow) Stick() {
+	if rand.Float64() < a.breakChance {
+		a.Break()
+		return
+	}
 	a.State = ArrowStuck
+	a.stuckAt = engine.elapsed
 }
 
-func (a *Arrow) Update() {
-	if a.State == ArrowStuck {
+// StuckFor returns how many seconds the arrow has been stuck in the ground.
+func (a *Arrow) StuckFor() float64 {
+	return engine.elapsed - a.stuckAt
+}
 
-	}
+func (a *Arrow) Break() {
+	a.Deactivate()
+	a.State = ArrowInactive
+}
 
+func (a *Arrow) Update() {
 	if !a.Active || a.State != ArrowFlying {
 		return
 	}
+	if a.move(engine.dt) {
+		a.Stick()
+	}
+}
+
+// move advances the flying arrow by dt seconds and reports whether it has
+// landed, either by passing the target or by hitting a wall.
+func (a *Arrow) move(dt float64) bool {
 	size := math.Sqrt(a.DistanceFromEnds())
 	oldDist := a.DistanceToTarget()
-	a.Pos = a.Pos.Add(a.vel.Scaled(engine.dt))
+	a.Pos = a.Pos.Add(a.vel.Scaled(dt))
 	newDist := a.DistanceToTarget()
 	// Maximum scaling should depend on the a.distance.
 	// If we shot on short distance then arrow should not rise high to the air.
@@ -132,21 +268,16 @@ func (a *Arrow) Update() {
 	a.ScaleXY.Y = a.baseScale + size*a.maxHeight/100
 	//fmt.Printf("%4.2f %4.2f\n", size, a.ScaleXY.X)
 	if newDist > oldDist {
-		// a.Active = false
-		// a.Visible = false
-		a.State = ArrowStuck
-		return
+		return true
 	}
 	acol := a.AbsCollider()
 	walls := world.GetColliders(acol)
 	for _, wall := range walls {
 		if collides(acol, wall) {
-			// a.Active = false
-			// a.Visible = false
-			a.State = ArrowStuck
-			return
+			return true
 		}
 	}
+	return false
 }
 
 func (a *Arrow) Draw(t pixel.Target) {
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+// BowBash is a short range melee attack with the bow. It does not kill enemies,
+// but stuns them and knocks them back.
+type BowBash struct {
+	Cooldown  float64 // seconds between two bashes
+	Duration  float64 // seconds of the swing animation
+	Range     float64 // how far from the hero enemies are hit
+	Cone      float64 // half-angle of the hit cone around the swing direction
+	Knockback float64 // initial speed of the knocked back enemy
+	Stun      float64 // seconds enemies stay stunned
+
+	started float64
+	ready   float64
+	dir     pixel.Vec
+	near    []Enemy
+}
+
+func NewBowBash() *BowBash {
+	return &BowBash{
+		Cooldown:  tuning.Hero.BashCooldown,
+		Duration:  0.2,
+		Range:     22,
+		Cone:      math.Pi / 3,
+		Knockback: 220,
+		Stun:      1.2,
+		started:   -1,
+	}
+}
+
+// Ready returns true when the cooldown is over.
+func (b *BowBash) Ready() bool {
+	return engine.elapsed >= b.ready
+}
+
+// Swinging returns true during the swing animation.
+func (b *BowBash) Swinging() bool {
+	return b.started >= 0 && engine.elapsed-b.started < b.Duration
+}
+
+// Start swings the bow from pos in direction dir and hits enemies in the cone.
+// It returns the number of enemies hit, or -1 if the bash is not ready yet.
+func (b *BowBash) Start(pos, dir pixel.Vec, horde *Horde) int {
+	if !b.Ready() {
+		return -1
+	}
+	b.started = engine.elapsed
+	b.ready = engine.elapsed + b.Cooldown
+	b.dir = dir
+
+	hit := 0
+	b.near = horde.Near(pos, b.Range, b.near[:0])
+	for _, e := range b.near {
+		toEnemy := e.Body().Pos.Sub(pos)
+		if toEnemy.Len() > b.Range || angleBetween(dir, toEnemy) > b.Cone {
+			continue
+		}
+		e.Stun(b.Stun, toEnemy.Unit().Scaled(b.Knockback))
+		hit++
+	}
+	return hit
+}
+
+// Animate places the bow for the current moment of the swing. It sweeps the
+// bow across the cone and pushes it a bit forward in the middle of the swing.
+func (b *BowBash) Animate(bow *Entity, pos pixel.Vec) {
+	if !b.Swinging() {
+		return
+	}
+	progress := (engine.elapsed - b.started) / b.Duration
+	angle := b.dir.Angle() + b.Cone*(1-2*progress)
+	reach := ArrowStartDistance - 3 + 5*math.Sin(progress*math.Pi)
+	bow.Pos = pos.Add(pixel.Unit(angle).Scaled(reach))
+	bow.Angle = angle
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/faiface/pixel"
+	"golang.org/x/image/colornames"
+)
+
+// Summoner is an enemy which calls other enemies to help it.
+type Summoner interface {
+	// Summon returns the kinds of enemies to spawn around the summoner now.
+	Summon() []EnemyKind
+}
+
+type BossPhase uint8
+
+const (
+	// BossSummon chases the hero slowly and calls slimes.
+	BossSummon BossPhase = iota
+	// BossCharge winds up and rushes at the hero.
+	BossCharge
+	// BossSpray sprays spits all around and calls slimes from time to time.
+	BossSpray
+)
+
+// Boss is the slime king. Instead of dying from one arrow it has a pool of
+// health and changes its tactics when the health drops below thresholds.
+type Boss struct {
+	enemy
+	crown     *pixel.Sprite
+	Health    float64
+	MaxHealth float64
+	speed     float64
+	drainRate float64
+	Phase     BossPhase
+
+	nextAttack float64
+	nextSummon float64
+	summons    []EnemyKind
+	sprayTurn  float64
+	sprays     int
+
+	windupEnd float64
+	chargeEnd float64
+	chargeDir pixel.Vec
+	flashEnd  float64
+}
+
+const bossSize = 2.8
+
+// Boss switches to the phase when its health drops below the threshold, as
+// a part of MaxHealth.
+var bossPhaseThresholds = [...]float64{
+	BossSummon: 1,
+	BossCharge: 2.0 / 3,
+	BossSpray:  1.0 / 3,
+}
+
+func NewBoss(spr, crown *pixel.Sprite) *Boss {
+	b := &Boss{enemy: newEnemy(spr, EnemyBoss, colornames.Crimson, bossSize)}
+	b.crown = crown
+	b.MaxHealth = tuning.Boss.Health
+	b.speed = tuning.Boss.Speed
+	b.drainRate = tuning.Boss.Drain
+	return b
+}
+
+func (b *Boss) Spawn(pos pixel.Vec) {
+	b.spawn(pos)
+	b.Health = b.MaxHealth
+	b.Phase = BossSummon
+	b.Status.Clear()
+	b.nextAttack = engine.elapsed + 3
+	b.nextSummon = engine.elapsed + 2
+	b.summons = b.summons[:0]
+	b.windupEnd = 0
+	b.chargeEnd = 0
+	b.sprays = 0
+}
+
+// Stun does not stop the king, it only shakes it a bit.
+func (b *Boss) Stun(duration float64, knockback pixel.Vec) {
+	b.move(knockback.Scaled(0.05))
+}
+
+func (b *Boss) Summon() []EnemyKind {
+	s := b.summons
+	b.summons = b.summons[:0]
+	return s
+}
+
+func (b *Boss) phaseFor(health float64) BossPhase {
+	p := BossSummon
+	for phase, threshold := range bossPhaseThresholds {
+		if health <= b.MaxHealth*threshold {
+			p = BossPhase(phase)
+		}
+	}
+	return p
+}
+
+func (b *Boss) Update(near *Nearby) *Arrow {
+	if !b.alive || !b.Active {
+		return nil
+	}
+
+	// The king shrugs off stuns and knockbacks, but burns and slows like anyone.
+	damage, _ := b.Status.Tick()
+	b.Health -= damage
+	if b.Health <= 0 {
+		b.Health = 0
+		b.Kill()
+		return nil
+	}
+	b.Phase = b.phaseFor(b.Health)
+	toHero := hero.Pos.Sub(b.Pos)
+	b.Color = b.Status.Tint(b.baseColor)
+	pace := b.Status.SpeedFactor()
+
+	switch {
+	case engine.elapsed < b.windupEnd:
+		// Shake before the charge so the hero can see it coming.
+		b.Color = colornames.Orange
+		b.Angle = math.Sin(engine.elapsed*40) / 6
+	case engine.elapsed < b.chargeEnd:
+		if !b.move(b.chargeDir.Scaled(tuning.Boss.ChargeSpeed * pace * engine.dt)) {
+			b.chargeEnd = engine.elapsed
+		}
+	default:
+		b.move(toHero.Unit().Scaled(b.speed * pace * engine.dt))
+		b.Angle += 0.3 * engine.dt
+		b.attack(toHero)
+	}
+
+	if engine.elapsed < b.flashEnd {
+		b.Color = colornames.White
+	}
+	if b.touchHero(near, b.drainRate) && engine.elapsed < b.chargeEnd {
+		// The charge throws the hero away.
+		hero.Status.Knock(b.chargeDir.Scaled(tuning.Boss.ChargeSpeed * 1.5))
+		b.chargeEnd = engine.elapsed
+	}
+	return b.hurtBy(near)
+}
+
+func (b *Boss) attack(toHero pixel.Vec) {
+	if engine.elapsed > b.nextSummon && b.Phase != BossCharge {
+		n := 3
+		every := 7.0
+		if b.Phase == BossSpray {
+			n, every = 2, 10
+		}
+		for i := 0; i < n; i++ {
+			b.summons = append(b.summons, EnemySlime)
+		}
+		b.nextSummon = engine.elapsed + every
+	}
+	if engine.elapsed < b.nextAttack {
+		return
+	}
+	switch b.Phase {
+	case BossCharge:
+		b.chargeDir = toHero.Unit()
+		b.windupEnd = engine.elapsed + tuning.Boss.Windup
+		b.chargeEnd = b.windupEnd + tuning.Boss.ChargeTime
+		b.nextAttack = b.chargeEnd + 2.5
+	case BossSpray:
+		b.spray(12)
+		b.nextAttack = engine.elapsed + 2.2
+	default:
+		b.nextAttack = engine.elapsed + 1
+	}
+}
+
+// spray shoots n spits evenly around the boss, every spray is turned a bit
+// so the hero can not stand in the same gap. Every third spray is a fan of
+// spits aimed at the hero instead.
+func (b *Boss) spray(n int) {
+	b.sprays++
+	if b.sprays%3 == 0 {
+		projectiles.Spread(b.Pos, hero.Pos, 5, math.Pi/3, 85, 8, colornames.Orangered)
+		return
+	}
+	b.sprayTurn += math.Pi / float64(n) * (0.5 + rand.Float64())
+	projectiles.Radial(b.Pos, n, b.sprayTurn, 70, 8, colornames.Orangered)
+}
+
+// hurtBy takes health for every arrow hitting the boss. Arrows do not go
+// through the boss. It returns the arrow which has finished the boss off.
+func (b *Boss) hurtBy(near *Nearby) *Arrow {
+	col := b.AbsCollider()
+	for _, arrow := range near.Arrows {
+		if !arrow.Kills(col) {
+			continue
+		}
+		difficulty.Hit()
+		arrow.Stick()
+		b.Health -= arrow.Damage
+		b.flashEnd = engine.elapsed + 0.08
+		if b.Health <= 0 {
+			b.Health = 0
+			b.Kill()
+			return arrow
+		}
+		arrow.Afflict(&b.Status)
+	}
+	return nil
+}
+
+func (b *Boss) Draw(t pixel.Target) {
+	b.enemy.Draw(t)
+	if b.alive {
+		m := pixel.IM.Moved(b.Pos.Add(pixel.V(0, 8*bossSize+3)))
+		b.crown.DrawColorMask(t, m, colornames.Gold)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
+	"golang.org/x/image/colornames"
+)
+
+// Stat is a number of the hero which buffs may change.
+type Stat uint8
+
+const (
+	StatMaxVel Stat = iota
+	StatAccel
+	StatArrowSpeed
+	StatDrawTime
+	StatDamage
+	numberOfStats
+)
+
+// Modifier changes a stat: the stat is (base + sum of Add) * product of Mul.
+// Zero Mul means no multiplication.
+type Modifier struct {
+	Stat Stat
+	Add  float64
+	Mul  float64
+}
+
+type BuffKind uint8
+
+const (
+	// BuffHaste makes the hero run faster.
+	BuffHaste BuffKind = iota
+	// BuffDoubleStrike doubles the damage of arrows, stacks up.
+	BuffDoubleStrike
+	// BuffQuickHands draws arrows faster and shoots them further.
+	BuffQuickHands
+	// BuffVolley draws arrows almost at once, the ability of the ranger.
+	BuffVolley
+	numberOfBuffKinds
+)
+
+type Buff struct {
+	Name      string
+	Modifiers []Modifier
+	Duration  float64
+	Stacking  Stacking
+	MaxStacks int
+	Color     color.RGBA
+}
+
+var buffs = [numberOfBuffKinds]Buff{
+	BuffHaste: {
+		Name: "Haste",
+		Modifiers: []Modifier{
+			{Stat: StatMaxVel, Mul: 1.5},
+			{Stat: StatAccel, Mul: 1.5},
+		},
+		Duration:  10,
+		Stacking:  StackStrongest,
+		MaxStacks: 1,
+		Color:     colornames.Cyan,
+	},
+	BuffDoubleStrike: {
+		Name:      "Double strike",
+		Modifiers: []Modifier{{Stat: StatDamage, Mul: 2}},
+		Duration:  10,
+		Stacking:  StackAdd,
+		MaxStacks: 2,
+		Color:     colornames.Magenta,
+	},
+	BuffQuickHands: {
+		Name: "Quick hands",
+		Modifiers: []Modifier{
+			{Stat: StatDrawTime, Mul: 0.5},
+			{Stat: StatArrowSpeed, Add: 50},
+		},
+		Duration:  12,
+		Stacking:  StackStrongest,
+		MaxStacks: 1,
+		Color:     colornames.Lime,
+	},
+	BuffVolley: {
+		Name: "Volley",
+		Modifiers: []Modifier{
+			{Stat: StatDrawTime, Mul: 0.15},
+			{Stat: StatArrowSpeed, Add: 30},
+		},
+		Duration:  4,
+		Stacking:  StackReplace,
+		MaxStacks: 1,
+		Color:     colornames.Palegreen,
+	},
+}
+
+type activeBuff struct {
+	stacks int
+	start  float64
+	until  float64
+}
+
+// Buffs holds the timed buffs of the hero.
+type Buffs struct {
+	active [numberOfBuffKinds]activeBuff
+}
+
+func (b *Buffs) Clear() {
+	b.active = [numberOfBuffKinds]activeBuff{}
+}
+
+func (b *Buffs) Active(kind BuffKind) bool {
+	return engine.elapsed < b.active[kind].until
+}
+
+// Apply gives the buff following its stacking rule.
+func (b *Buffs) Apply(kind BuffKind) {
+	def := &buffs[kind]
+	a := &b.active[kind]
+	until := engine.elapsed + def.Duration
+	if !b.Active(kind) || def.Stacking == StackReplace {
+		*a = activeBuff{stacks: 1, start: engine.elapsed, until: until}
+		return
+	}
+	if def.Stacking == StackAdd && a.stacks < def.MaxStacks {
+		a.stacks++
+	}
+	if def.Stacking == StackAdd || until > a.until {
+		a.start = engine.elapsed
+		a.until = until
+	}
+}
+
+// Stat returns the value of the stat with base value base changed by all
+// active buffs. Every stack applies the modifiers of the buff once more.
+func (b *Buffs) Stat(stat Stat, base float64) float64 {
+	add, mul := 0.0, 1.0
+	for kind := range buffs {
+		if !b.Active(BuffKind(kind)) {
+			continue
+		}
+		stacks := float64(b.active[kind].stacks)
+		for _, m := range buffs[kind].Modifiers {
+			if m.Stat != stat {
+				continue
+			}
+			add += m.Add * stacks
+			if m.Mul != 0 {
+				mul *= math.Pow(m.Mul, stacks)
+			}
+		}
+	}
+	return (base + add) * mul
+}
+
+func (b *Buffs) remaining(kind BuffKind) float64 {
+	a := &b.active[kind]
+	if !b.Active(kind) || a.until <= a.start {
+		return 0
+	}
+	return (a.until - engine.elapsed) / (a.until - a.start)
+}
+
+// DrawBuffIcons draws icons of active buffs in a row starting at pos with
+// the time left below every icon.
+func DrawBuffIcons(t pixel.Target, imd *imdraw.IMDraw, pos pixel.Vec, b *Buffs, icons []*pixel.Sprite) {
+	for kind, icon := range icons {
+		k := BuffKind(kind)
+		if icon == nil || !b.Active(k) {
+			continue
+		}
+		pos = drawTimerIcon(t, imd, pos, icon, buffs[k].Color, b.remaining(k), b.active[k].stacks)
+	}
+}
+
+// drawTimerIcon draws the icon at pos with a bar of the remaining time below
+// it and a pip for every stack when there are several. It returns the position
+// of the next icon.
+func drawTimerIcon(t pixel.Target, imd *imdraw.IMDraw, pos pixel.Vec, icon *pixel.Sprite, col color.RGBA, remaining float64, stacks int) pixel.Vec {
+	const scale, gap = 3.0, 8.0
+	w := icon.Frame().W() * scale
+	center := pos.Add(pixel.V(w/2, w/2))
+	icon.DrawColorMask(t, pixel.IM.Scaled(pixel.ZV, scale).Moved(center), col)
+
+	imd.Color = col
+	imd.Push(pos.Sub(pixel.V(0, 6)), pos.Add(pixel.V(w*remaining, -3)))
+	imd.Rectangle(0)
+	for i := 0; i < stacks && stacks > 1; i++ {
+		p := pos.Add(pixel.V(3+float64(i)*6, w+4))
+		imd.Push(p, p.Add(pixel.V(4, 4)))
+		imd.Rectangle(0)
+	}
+	return pos.Add(pixel.V(w+gap, 0))
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
+	"github.com/faiface/pixel/pixelgl"
+	"github.com/faiface/pixel/text"
+	"golang.org/x/image/colornames"
+)
+
+// Ability is the unique skill of a hero class, used with C.
+type Ability uint8
+
+const (
+	// AbilityVolley draws arrows almost at once for a few seconds.
+	AbilityVolley Ability = iota
+	// AbilityStomp stuns and throws back enemies around the hero.
+	AbilityStomp
+	// AbilityBlink moves the hero towards the cursor in an instant.
+	AbilityBlink
+	// AbilityRush refills the stamina and readies the dash at once.
+	AbilityRush
+	// AbilityBulwark heals the hero and shields it from touch for a moment.
+	AbilityBulwark
+	numberOfAbilities
+)
+
+var abilityNames = [...]string{
+	AbilityVolley:  "Volley",
+	AbilityStomp:   "Stomp",
+	AbilityBlink:   "Blink",
+	AbilityRush:    "Rush",
+	AbilityBulwark: "Bulwark",
+}
+
+// HeroClass is the archetype the hero is played as. Its numbers are in the
+// classes of the tuning.
+type HeroClass struct {
+	ID          string
+	Name        string
+	Description string
+	Arrows      []ArrowType
+	Tint        color.RGBA
+	Ability     Ability
+}
+
+// ClassStats are the tuned numbers of a hero class. Speed, Health and Dash
+// scale the tuning of the hero.
+type ClassStats struct {
+	Speed    float64 `json:"speed"`
+	Health   float64 `json:"health"`
+	Dash     float64 `json:"dash"` // multiplies the dash cooldown
+	Pierce   int     `json:"pierce"`
+	Ricochet int     `json:"ricochet"`
+	Cooldown float64 `json:"cooldown"` // seconds between two uses of the ability
+}
+
+func (s *ClassStats) validate() error {
+	if s.Speed <= 0 || s.Health <= 0 || s.Dash <= 0 || s.Cooldown <= 0 {
+		return fmt.Errorf("speed, health, dash and cooldown must be positive")
+	}
+	if s.Pierce < 0 || s.Ricochet < 0 {
+		return fmt.Errorf("pierce and ricochet must not be negative")
+	}
+	return nil
+}
+
+// ClassTable holds the stats of every class by its ID.
+type ClassTable map[string]ClassStats
+
+// UnmarshalJSON decodes the classes over the stats already in the table, so
+// a partial tuning may change a single number of a class.
+func (t *ClassTable) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if *t == nil {
+		*t = make(ClassTable)
+	}
+	for id, entry := range raw {
+		if findClass(id) == nil {
+			return fmt.Errorf("unknown class %q", id)
+		}
+		s := (*t)[id]
+		dec := json.NewDecoder(bytes.NewReader(entry))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&s); err != nil {
+			return fmt.Errorf("class %s: %v", id, err)
+		}
+		(*t)[id] = s
+	}
+	return nil
+}
+
+// Stats returns the numbers of the class in the current tuning.
+func (c *HeroClass) Stats() ClassStats {
+	return tuning.Classes[c.ID]
+}
+
+var heroClasses = []HeroClass{
+	{
+		ID:          "ranger",
+		Name:        "Ranger",
+		Description: "Fast on the feet\n3 arrows",
+		Arrows:      []ArrowType{ArrowWooden, ArrowWooden, ArrowWooden},
+		Tint:        colornames.Palegreen,
+		Ability:     AbilityVolley,
+	},
+	{
+		ID:          "heavy",
+		Name:        "Heavy archer",
+		Description: "Slow but tough\n5 arrows, 2 of steel\nShots pierce",
+		Arrows:      []ArrowType{ArrowSteel, ArrowSteel, ArrowWooden, ArrowWooden, ArrowWooden},
+		Tint:        colornames.Lightsteelblue,
+		Ability:     AbilityStomp,
+	},
+	{
+		ID:          "trickster",
+		Name:        "Trickster",
+		Description: "Quick dash\n3 arrows\nShots ricochet",
+		Arrows:      []ArrowType{ArrowWooden, ArrowWooden, ArrowWooden},
+		Tint:        colornames.Plum,
+		Ability:     AbilityBlink,
+	},
+	// Classes below are unlocked in the profile.
+	{
+		ID:          "swift",
+		Name:        "Swift",
+		Description: "Runs and dashes more\nLess health\n3 arrows",
+		Arrows:      []ArrowType{ArrowWooden, ArrowWooden, ArrowWooden},
+		Tint:        colornames.Lightskyblue,
+		Ability:     AbilityRush,
+	},
+	{
+		ID:          "sturdy",
+		Name:        "Sturdy",
+		Description: "More health\nRuns slower\n4 arrows",
+		Arrows:      []ArrowType{ArrowWooden, ArrowWooden, ArrowWooden, ArrowWooden},
+		Tint:        colornames.Sandybrown,
+		Ability:     AbilityBulwark,
+	},
+}
+
+func findClass(id string) *HeroClass {
+	for i := range heroClasses {
+		if heroClasses[i].ID == id {
+			return &heroClasses[i]
+		}
+	}
+	return nil
+}
+
+// Equip gives the hero the stats and the look of the class and the quiver
+// its bonuses. Starting arrows are left to the caller.
+func (c *HeroClass) Equip(h *Hero, q *Quiver) {
+	s := c.Stats()
+	h.Class = c
+	h.Tint = c.Tint
+	h.maxVel *= s.Speed
+	h.accel *= s.Speed
+	h.maxHealth *= s.Health
+	h.health = h.maxHealth
+	h.DashCooldown *= s.Dash
+	q.PierceBonus += s.Pierce
+	q.Ricochet += s.Ricochet
+}
+
+const stompTime = 0.25 // seconds the shock wave is seen
+
+// UseAbility uses the ability of the class towards target. It returns false
+// while the ability cools down or can not be used.
+func (h *Hero) UseAbility(target pixel.Vec, horde *Horde) bool {
+	c := h.Class
+	if c == nil || !h.Alive() || engine.elapsed < h.nextAbility || h.Status.Active(StatusStun) {
+		return false
+	}
+	switch c.Ability {
+	case AbilityVolley:
+		h.Buffs.Apply(BuffVolley)
+	case AbilityStomp:
+		h.stomp(horde)
+	case AbilityBlink:
+		if !h.blink(target) {
+			return false
+		}
+	case AbilityRush:
+		h.Stamina.Refill()
+		h.nextDash = engine.elapsed
+	case AbilityBulwark:
+		h.bulwark()
+	}
+	h.nextAbility = engine.elapsed + c.Stats().Cooldown
+	return true
+}
+
+// AbilityReady returns the part of the cooldown which has passed, 1 when the
+// ability can be used again.
+func (h *Hero) AbilityReady() float64 {
+	if h.Class == nil {
+		return 1
+	}
+	return pixel.Clamp(1-(h.nextAbility-engine.elapsed)/h.Class.Stats().Cooldown, 0, 1)
+}
+
+func (h *Hero) stomp(horde *Horde) {
+	h.stompAt = engine.elapsed
+	for _, e := range horde.Near(h.Pos, tuning.Ability.StompRadius, nil) {
+		toEnemy := e.Body().Pos.Sub(h.Pos)
+		if toEnemy.Len() > tuning.Ability.StompRadius {
+			continue
+		}
+		e.Stun(tuning.Ability.StompStun, toEnemy.Unit().Scaled(tuning.Ability.StompKnockback))
+	}
+}
+
+// bulwark heals a part of the max health and makes the hero immune for a
+// while. The hero flashes as long as it lasts.
+func (h *Hero) bulwark() {
+	h.Damage(h.maxHealth * tuning.Ability.BulwarkHeal)
+	h.immuneEnd = math.Max(h.immuneEnd, engine.elapsed+tuning.Ability.BulwarkTime)
+	h.flashEnd = math.Max(h.flashEnd, h.immuneEnd)
+}
+
+// blink moves the hero as far towards target as the blink range allows, stepping
+// back until there is no wall in the way. Ghosts are left along the path.
+func (h *Hero) blink(target pixel.Vec) bool {
+	dir := target.Sub(h.Pos)
+	dist := math.Min(dir.Len(), tuning.Ability.BlinkRange)
+	if dist < 1 {
+		return false
+	}
+	dir = dir.Unit()
+	col := h.AbsCollider()
+	in := world.Interior()
+	for d := dist; d > 0; d -= 4 {
+		delta := dir.Scaled(d)
+		c := col.Moved(delta)
+		if !in.Contains(c.Min) || !in.Contains(c.Max) || collidesAny(c, world.GetColliders(c)) {
+			continue
+		}
+		const ghosts = 6
+		for i := 0; i < ghosts; i++ {
+			h.trail = append(h.trail, trailPoint{h.Pos.Add(delta.Scaled(float64(i) / ghosts)), engine.elapsed})
+		}
+		h.Pos = h.Pos.Add(delta)
+		h.immuneEnd = math.Max(h.immuneEnd, engine.elapsed+h.ImmuneTime)
+		return true
+	}
+	return false
+}
+
+func collidesAny(c pixel.Rect, walls []pixel.Rect) bool {
+	for _, w := range walls {
+		if collides(c, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// DrawAbility draws the shock wave of the stomp.
+func (h *Hero) DrawAbility(imd *imdraw.IMDraw) {
+	t := (engine.elapsed - h.stompAt) / stompTime
+	if h.stompAt == 0 || t > 1 {
+		return
+	}
+	imd.Color = fade(colornames.Lightsteelblue, 1-t)
+	imd.Push(h.Pos)
+	imd.Circle(tuning.Ability.StompRadius*t, 2)
+}
+
+// ChooseClass shows the title screen until a class is picked with its number
+// key or a click. Classes which allowed rejects are shown locked. It returns
+// nil when the player leaves with Esc.
+func ChooseClass(atlas *text.Atlas, spr *pixel.Sprite, allowed func(c *HeroClass) bool) *HeroClass {
+	win := engine.win
+	b := win.Bounds()
+	title := text.New(pixel.V(b.Center().X, b.Max.Y-140), atlas)
+	centerLines(title, "A World\nChoose your hero")
+
+	n := len(heroClasses)
+	colW := b.W() / (float64(n) + 0.5)
+	cards := make([]pixel.Rect, n)
+	texts := make([]*text.Text, n)
+	open := make([]bool, n)
+	widest := 0.0
+	for i := range heroClasses {
+		c := &heroClasses[i]
+		open[i] = allowed(c)
+		x := b.Center().X + (float64(i)-float64(n-1)/2)*colW
+		cards[i] = pixel.R(x-colW/2+16, b.Center().Y-200, x+colW/2-16, b.Center().Y+180)
+		texts[i] = text.New(pixel.V(x, b.Center().Y-30), atlas)
+		s := fmt.Sprintf("%d: %s\n\n%s\n%s: every %.0fs",
+			i+1, c.Name, c.Description, abilityNames[c.Ability], c.Stats().Cooldown)
+		if !open[i] {
+			s = fmt.Sprintf("%d: %s\n\nLocked\nBuy with -buy %s", i+1, c.Name, c.ID)
+		}
+		centerLines(texts[i], s)
+		widest = math.Max(widest, texts[i].Bounds().W())
+	}
+	// Shrink the text when many cards make them narrow.
+	scale := math.Min(2, (cards[0].W()-16)/widest)
+	keys := []pixelgl.Button{pixelgl.Key1, pixelgl.Key2, pixelgl.Key3, pixelgl.Key4, pixelgl.Key5,
+		pixelgl.Key6, pixelgl.Key7, pixelgl.Key8, pixelgl.Key9}
+	if len(keys) > n {
+		keys = keys[:n]
+	}
+	imd := imdraw.New(nil)
+
+	for !win.Closed() {
+		if win.JustPressed(pixelgl.KeyEscape) {
+			return nil
+		}
+		hover := -1
+		for i, r := range cards {
+			if open[i] && r.Contains(win.MousePosition()) {
+				hover = i
+			}
+		}
+		for i, key := range keys {
+			if open[i] && (win.JustPressed(key) || (i == hover && win.JustPressed(pixelgl.MouseButton1))) {
+				return &heroClasses[i]
+			}
+		}
+
+		win.SetMatrix(pixel.IM)
+		win.Clear(darkblue)
+		imd.Clear()
+		for i, r := range cards {
+			imd.Color = darkgray
+			if i == hover {
+				imd.Color = heroClasses[i].Tint
+			}
+			imd.Push(r.Min, r.Max)
+			imd.Rectangle(2)
+		}
+		imd.Draw(win)
+		title.Draw(win, pixel.IM.Scaled(title.Orig, 4))
+		for i, t := range texts {
+			c := &heroClasses[i]
+			pos := pixel.V(t.Orig.X, b.Center().Y+90)
+			col := mix(colornames.White, c.Tint, 0.5)
+			if !open[i] {
+				col = darkgray
+			}
+			spr.DrawColorMask(win, pixel.IM.Scaled(pixel.ZV, 8).Moved(pos), col)
+			t.Draw(win, pixel.IM.Scaled(t.Orig, scale))
+		}
+		win.Update()
+		time.Sleep(15 * time.Millisecond)
+	}
+	return nil
+}
+
+// centerLines writes every line of s centered on the origin of t.
+func centerLines(t *text.Text, s string) {
+	for _, line := range strings.Split(s, "\n") {
+		t.Dot.X -= t.BoundsOf(line).W() / 2
+		fmt.Fprintln(t, line)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+// Combo rewards kills made in quick succession and several kills with one arrow.
+type Combo struct {
+	Window   float64 // seconds between kills to keep the chain going
+	MaxChain int
+	chain    int
+	current  int // multiplier of the last kill
+	lastKill float64
+	best     int
+}
+
+func NewCombo(window float64, maxChain int) *Combo {
+	return &Combo{Window: window, MaxChain: maxChain}
+}
+
+// Kill registers a kill by arrow a and returns the score multiplier for it.
+// Every kill within the window extends the chain, every extra slime pierced
+// by the same arrow adds one more to the multiplier.
+func (c *Combo) Kill(a *Arrow) int {
+	if engine.elapsed-c.lastKill > c.Window {
+		c.chain = 0
+	}
+	c.lastKill = engine.elapsed
+	if c.chain < c.MaxChain {
+		c.chain++
+	}
+	m := c.chain
+	if a != nil && a.KillCount() > 1 {
+		m += a.KillCount() - 1
+	}
+	c.current = m
+	if m > c.best {
+		c.best = m
+	}
+	return m
+}
+
+// Multiplier returns the multiplier of the last kill or 0 when the chain is broken.
+func (c *Combo) Multiplier() int {
+	if engine.elapsed-c.lastKill > c.Window {
+		return 0
+	}
+	return c.current
+}
+
+// Remaining returns the part of the window left to extend the chain, in range 0 ... 1.
+func (c *Combo) Remaining() float64 {
+	left := c.Window - (engine.elapsed - c.lastKill)
+	if left <= 0 || c.Window == 0 {
+		return 0
+	}
+	return left / c.Window
+}
+
+func (c *Combo) Best() int {
+	return c.best
+}
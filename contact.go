@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// ContactModel is what touching an enemy does to the hero.
+type ContactModel uint8
+
+const (
+	// ContactDrain takes health every frame of the touch and slows the hero.
+	ContactDrain ContactModel = iota
+	// ContactHit takes a chunk of health at once, throws the hero back and
+	// makes it invulnerable for a moment.
+	ContactHit
+)
+
+var contactModelNames = [...]string{
+	ContactDrain: "drain",
+	ContactHit:   "hit",
+}
+
+func (m *ContactModel) UnmarshalText(text []byte) error {
+	for i, name := range contactModelNames {
+		if name == string(text) {
+			*m = ContactModel(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown contact model %q", text)
+}
+
+// Contact defines the touch of an enemy kind. Damage and Knockback are used
+// by the hit model only, drain rates belong to the enemies.
+type Contact struct {
+	Model     ContactModel `json:"model"`
+	Damage    float64      `json:"damage"`
+	Knockback float64      `json:"knockback"` // speed the hero is thrown away with
+}
+
+func (c *Contact) validate() error {
+	if c.Damage < 0 || c.Knockback < 0 {
+		return fmt.Errorf("damage and knockback must not be negative")
+	}
+	if c.Model == ContactHit && c.Damage == 0 {
+		return fmt.Errorf("hit needs damage")
+	}
+	return nil
+}
+
+// ContactTable holds the contact of every enemy kind.
+type ContactTable map[EnemyKind]Contact
+
+// UnmarshalJSON decodes the kinds over the contacts already in the table, so
+// a partial tuning may change a single number of a kind.
+func (t *ContactTable) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if *t == nil {
+		*t = make(ContactTable)
+	}
+	for name, entry := range raw {
+		var kind EnemyKind
+		if err := kind.UnmarshalText([]byte(name)); err != nil {
+			return err
+		}
+		c := (*t)[kind]
+		dec := json.NewDecoder(bytes.NewReader(entry))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&c); err != nil {
+			return fmt.Errorf("contact of %s: %v", kind, err)
+		}
+		(*t)[kind] = c
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/faiface/pixel"
+	"golang.org/x/image/colornames"
+)
+
+// CorpseRules define how long dead enemies lie around and what they do.
+type CorpseRules struct {
+	Lifetime float64 // seconds before a corpse is gone, 0 keeps corpses until their slot is needed
+	Fade     float64 // seconds of fading out at the end of the lifetime
+	Max      int     // most corpses lying around at once, the oldest go first, 0 for no limit
+	// Slow is the part of its velocity the hero keeps after walking over
+	// corpses for a second, 1 does not slow the hero.
+	Slow float64
+	// Harvest is the chance for a corpse to hold an arrow which the hero
+	// picks up by walking over it.
+	Harvest float64
+}
+
+// Reanimator is an enemy which brings corpses back to life.
+type Reanimator interface {
+	// Reanimate returns how many corpses within radius to raise now.
+	Reanimate() (radius float64, n int)
+}
+
+// corpseDied is called when the enemy in the slot dies.
+func (h *Horde) corpseDied(slot int) {
+	h.diedAt[slot] = engine.elapsed
+	h.loot[slot] = rand.Float64() < h.Corpses.Harvest
+}
+
+// UpdateCorpses fades out old corpses, removes the ones over the limit and
+// lets the hero walk over the rest. For every arrow found in a corpse it
+// calls harvest, which returns false when the hero has no room for the arrow.
+func (h *Horde) UpdateCorpses(harvest func() bool) {
+	r := &h.Corpses
+	heroCol := hero.AbsCollider()
+	slowed := false
+	h.ids = h.ids[:0]
+	for i, e := range h.enemies {
+		b := e.Body()
+		if e.Alive() || !b.Active {
+			continue
+		}
+		age := engine.elapsed - h.diedAt[i]
+		if r.Lifetime > 0 && age > r.Lifetime {
+			b.Deactivate()
+			continue
+		}
+		if hero.Alive() && collides(heroCol, e.AbsCollider()) {
+			slowed = true
+			if h.loot[i] && harvest() {
+				b.Deactivate()
+				continue
+			}
+		}
+		h.ids = append(h.ids, i)
+	}
+	if slowed && r.Slow < 1 {
+		hero.SlowDown(math.Pow(r.Slow, engine.dt))
+	}
+
+	if r.Max > 0 && len(h.ids) > r.Max {
+		sort.Slice(h.ids, func(a, b int) bool {
+			return h.diedAt[h.ids[a]] < h.diedAt[h.ids[b]]
+		})
+		extra := len(h.ids) - r.Max
+		for _, i := range h.ids[:extra] {
+			h.enemies[i].Body().Deactivate()
+		}
+		h.ids = h.ids[extra:]
+	}
+
+	for _, i := range h.ids {
+		alpha := 1.0
+		if r.Lifetime > 0 && r.Fade > 0 {
+			alpha = pixel.Clamp((r.Lifetime-(engine.elapsed-h.diedAt[i]))/r.Fade, 0, 1)
+		}
+		col := colornames.Grey
+		if h.loot[i] {
+			// Corpses with arrows are a bit lighter so the hero knows where to look.
+			col = colornames.Silver
+		}
+		h.enemies[i].Body().Color = fade(col, alpha)
+	}
+}
+
+// reanimate brings back to life up to n corpses within radius from pos.
+// Bosses and reanimators stay dead.
+func (h *Horde) reanimate(pos pixel.Vec, radius float64, n int) {
+	for _, e := range h.enemies {
+		if n == 0 {
+			return
+		}
+		b := e.Body()
+		if e.Alive() || !b.Active || e.Kind() == EnemyBoss {
+			continue
+		}
+		if _, ok := e.(Reanimator); ok || b.Pos.Sub(pos).Len() > radius {
+			continue
+		}
+		e.Spawn(b.Pos)
+		// Raised enemies need a moment to get up.
+		e.Stun(0.8, pixel.ZV)
+		n--
+	}
+}
+
+// fade scales the premultiplied color c by alpha.
+func fade(c color.RGBA, alpha float64) color.RGBA {
+	return color.RGBA{
+		R: uint8(float64(c.R) * alpha),
+		G: uint8(float64(c.G) * alpha),
+		B: uint8(float64(c.B) * alpha),
+		A: uint8(float64(c.A) * alpha),
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/faiface/pixel"
+)
+
+// DifficultyBounds are the limits within which the difficulty adapts to
+// the player. Level 1 is the difficulty as designed.
+type DifficultyBounds struct {
+	Min  float64 `json:"min"`
+	Max  float64 `json:"max"`
+	Rate float64 `json:"rate"` // the most the level changes per second
+}
+
+var defaultDifficulty = DifficultyBounds{Min: 0.7, Max: 1.6, Rate: 0.02}
+
+func (b *DifficultyBounds) validate() error {
+	if b.Min <= 0 || b.Min > 1 || b.Max < 1 {
+		return fmt.Errorf("difficulty: min must be in (0, 1] and max at least 1")
+	}
+	if b.Rate < 0 {
+		return fmt.Errorf("difficulty: negative rate")
+	}
+	return nil
+}
+
+const (
+	// The director judges the player by the last difficultyWindow seconds.
+	difficultyWindow = 20
+	// Players who are doing fine kill this many enemies per minute.
+	difficultyKillRate = 12
+)
+
+// Weights of the measures of the performance.
+const (
+	weightHealth   = 0.4
+	weightAccuracy = 0.25
+	weightKillRate = 0.2
+	weightGround   = 0.15
+)
+
+type performanceSample struct {
+	health             float64
+	shots, hits, kills int
+}
+
+// Difficulty tracks how well the player is doing and raises or lowers the
+// level of the difficulty accordingly. The level scales the spawn rate, the
+// share of tough enemies and the speed of slimes.
+type Difficulty struct {
+	DifficultyBounds
+	Level   float64
+	Enabled bool
+
+	samples    []performanceSample // one per second, the oldest first
+	nextSample float64
+	current    performanceSample
+
+	// The last judgement, every measure is from -1 (struggling) to 1 (too easy).
+	Health      float64
+	Accuracy    float64
+	KillRate    float64
+	Ground      float64
+	Performance float64
+}
+
+func NewDifficulty(bounds DifficultyBounds, enabled bool) *Difficulty {
+	return &Difficulty{DifficultyBounds: bounds, Level: 1, Enabled: enabled}
+}
+
+// Shot registers an arrow shot by the hero.
+func (d *Difficulty) Shot() {
+	d.current.shots++
+}
+
+// Hit registers an arrow striking an enemy, whether it kills, wounds or
+// bounces off the armor.
+func (d *Difficulty) Hit() {
+	d.current.hits++
+}
+
+// Kill registers a killed enemy.
+func (d *Difficulty) Kill() {
+	d.current.kills++
+}
+
+// Update judges the player by the health of the hero and the number of
+// arrows lying on the ground out of all arrows owned, and moves the level.
+func (d *Difficulty) Update(health, maxHealth float64, grounded, owned int) {
+	if engine.elapsed >= d.nextSample {
+		d.nextSample = engine.elapsed + 1
+		d.current.health = health
+		d.samples = append(d.samples, d.current)
+		if len(d.samples) > difficultyWindow {
+			d.samples = d.samples[1:]
+		}
+		d.current = performanceSample{}
+		d.judge(maxHealth, grounded, owned)
+	}
+	if d.Enabled {
+		d.Level = pixel.Clamp(d.Level+d.Performance*d.Rate*engine.dt, d.Min, d.Max)
+	}
+}
+
+func (d *Difficulty) judge(maxHealth float64, grounded, owned int) {
+	if len(d.samples) < 2 {
+		return
+	}
+	first, last := d.samples[0], d.samples[len(d.samples)-1]
+	var shots, hits, kills int
+	for _, s := range d.samples {
+		shots += s.shots
+		hits += s.hits
+		kills += s.kills
+	}
+	minutes := float64(len(d.samples)) / 60
+
+	// Losing a fifth of the health over the window is as bad as it gets.
+	d.Health = pixel.Clamp((last.health-first.health)/maxHealth*5, -1, 1)
+	if last.health == maxHealth {
+		d.Health = 1
+	}
+	d.Accuracy = 0
+	if shots > 0 {
+		// Piercing and ricocheting arrows may hit more than once.
+		d.Accuracy = pixel.Clamp(float64(hits)/float64(shots), 0, 1)*2 - 1
+	}
+	d.KillRate = pixel.Clamp(float64(kills)/minutes/difficultyKillRate-1, -1, 1)
+	d.Ground = 0
+	if owned > 0 {
+		// Arrows lying around mean the hero has no time to collect them.
+		d.Ground = 1 - 2*float64(grounded)/float64(owned)
+	}
+	d.Performance = weightHealth*d.Health + weightAccuracy*d.Accuracy +
+		weightKillRate*d.KillRate + weightGround*d.Ground
+}
+
+// SpawnRate is the multiplier for the frequency of spawns.
+func (d *Difficulty) SpawnRate() float64 {
+	return d.Level
+}
+
+// Toughness is the multiplier for the chance of tough enemies.
+func (d *Difficulty) Toughness() float64 {
+	return d.Level * d.Level
+}
+
+// Speed is the multiplier for the speed of slimes.
+func (d *Difficulty) Speed() float64 {
+	return 1 + (d.Level-1)/2
+}
+
+// Report writes the judgement and the decisions of the director to w.
+func (d *Difficulty) Report(w io.Writer) {
+	state := "adaptive"
+	if !d.Enabled {
+		state = "fixed"
+	}
+	fmt.Fprintf(w, "difficulty %4.2f (%s, %.2f..%.2f)\n", d.Level, state, d.Min, d.Max)
+	fmt.Fprintf(w, "  health   %+5.2f\n", d.Health)
+	fmt.Fprintf(w, "  accuracy %+5.2f\n", d.Accuracy)
+	fmt.Fprintf(w, "  killrate %+5.2f\n", d.KillRate)
+	fmt.Fprintf(w, "  ground   %+5.2f\n", d.Ground)
+	fmt.Fprintf(w, "  overall  %+5.2f\n", d.Performance)
+	fmt.Fprintf(w, "spawns x%.2f tough x%.2f speed x%.2f\n", d.SpawnRate(), d.Toughness(), d.Speed())
+}
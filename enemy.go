@@ -1,127 +1,595 @@
 package main
 
 import (
+	"fmt"
+	"image/color"
+	"math"
 	"math/rand"
 
 	"github.com/faiface/pixel"
 	"golang.org/x/image/colornames"
 )
 
-type Slime struct {
+// Enemy is anything that hunts the hero and dies from arrows.
+type Enemy interface {
+	// Spawn places the enemy at pos and brings it to life.
+	Spawn(pos pixel.Vec)
+	// Update moves the enemy and returns the arrow which killed it during this
+	// update or nil.
+	Update(near *Nearby) *Arrow
+	Draw(t pixel.Target)
+	Kill()
+	// Stun stops the enemy for duration seconds and pushes it with velocity knockback.
+	Stun(duration float64, knockback pixel.Vec)
+	AbsCollider() pixel.Rect
+	Body() *Entity
+	Alive() bool
+	Kind() EnemyKind
+}
+
+// Nearby is what the broadphase of the horde found around an enemy.
+type Nearby struct {
+	Arrows    []*Arrow // flying arrows which may hit the enemy
+	Hero      bool     // whether the enemy may touch the hero
+	Neighbors []Neighbor
+}
+
+// Neighbor is another living enemy within the flocking radius.
+type Neighbor struct {
+	Pos, Vel pixel.Vec
+}
+
+// Flocking tunes how enemies of one kind move in a crowd, boids-style.
+type Flocking struct {
+	Radius     float64 // neighbors closer than this are taken into account
+	Separation float64 // how strongly to keep away from neighbors
+	Alignment  float64 // how strongly to move the same way as neighbors
+	Cohesion   float64 // how strongly to stay with neighbors
+}
+
+var enemyFlocking = [numberOfEnemyKinds]Flocking{
+	EnemySlime:       {Radius: 16, Separation: 1.4, Alignment: 0.1, Cohesion: 0.05},
+	EnemySlimeling:   {Radius: 11, Separation: 1.2, Alignment: 0.2, Cohesion: 0.1},
+	EnemyBigSlime:    {Radius: 28, Separation: 1.6},
+	EnemySpitter:     {Radius: 24, Separation: 1},
+	EnemyBeetle:      {Radius: 20, Separation: 0.8, Alignment: 0.3},
+	EnemyNecromancer: {Radius: 30, Separation: 1.2},
+}
+
+// Splitter is an enemy which falls apart into other enemies when it dies.
+type Splitter interface {
+	SplitInto() []EnemyKind
+}
+
+type EnemyKind uint8
+
+const (
+	EnemySlime EnemyKind = iota
+	EnemySlimeling
+	EnemyBigSlime
+	EnemySpitter
+	EnemyBeetle
+	EnemyNecromancer
+	EnemyBoss
+	numberOfEnemyKinds
+)
+
+var enemyKindNames = [...]string{
+	EnemySlime:       "slime",
+	EnemySlimeling:   "slimeling",
+	EnemyBigSlime:    "bigslime",
+	EnemySpitter:     "spitter",
+	EnemyBeetle:      "beetle",
+	EnemyNecromancer: "necromancer",
+	EnemyBoss:        "boss",
+}
+
+func (k EnemyKind) String() string {
+	if int(k) < len(enemyKindNames) {
+		return enemyKindNames[k]
+	}
+	return fmt.Sprintf("EnemyKind(%d)", k)
+}
+
+// UnmarshalText parses the name of the enemy kind, so kinds can be used in data files.
+func (k *EnemyKind) UnmarshalText(text []byte) error {
+	for i, name := range enemyKindNames {
+		if name == string(text) {
+			*k = EnemyKind(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown enemy kind %q", text)
+}
+
+// enemy holds the state shared by all kinds of enemies.
+type enemy struct {
 	Entity
-	speed          float64
-	drainRate      float64
-	rotation       float64
-	fixedDirection pixel.Vec
-	fixed          bool
-	Alive          bool
+	kind      EnemyKind
+	baseColor color.RGBA
+	alive     bool
+	Status    Statuses
+	wounds    float64 // damage taken from effects, the enemy dies at 1
 }
 
-func NewSlime(spr *pixel.Sprite) *Slime {
-	sl := &Slime{Entity: *NewEntity(spr, pixel.ZV)}
-	sl.ScaleXY = pixel.V(1, 1)
-	sl.Color = colornames.Red
-	s := float64(world.gridSize) / 2.5
+func newEnemy(spr *pixel.Sprite, kind EnemyKind, col color.RGBA, size float64) enemy {
+	e := enemy{Entity: *NewEntity(spr, pixel.ZV), kind: kind, baseColor: col}
+	e.Color = col
+	s := float64(world.gridSize) / 2.5 * size
 	r := pixel.R(-s, -s, s, s)
-	sl.Collider = &r
-	sl.speed = 40
-	sl.drainRate = 120
-	sl.Active = false
-	sl.Visible = false
-	sl.Alive = false
-	return sl
+	e.Collider = &r
+	e.ScaleXY = pixel.V(size, size)
+	e.Deactivate()
+	return e
 }
 
-func (s *Slime) Spawn() {
-	p := world.RandomVec()
-	for hero.Pos.Sub(p).Len() < 102 {
-		p = world.RandomVec()
+func (e *enemy) spawn(pos pixel.Vec) {
+	e.Pos = pos
+	e.Status.Clear()
+	e.wounds = 0
+	e.Color = e.baseColor
+	e.Activate()
+	e.alive = true
+}
+
+func (e *enemy) Body() *Entity {
+	return &e.Entity
+}
+
+func (e *enemy) Alive() bool {
+	return e.alive
+}
+
+func (e *enemy) Kind() EnemyKind {
+	return e.kind
+}
+
+func (e *enemy) Kill() {
+	e.alive = false
+	e.Color = colornames.Grey
+}
+
+func (e *enemy) Stun(duration float64, knockback pixel.Vec) {
+	e.Status.Apply(StatusStun, 1, duration)
+	e.Status.Knock(knockback)
+	e.Color = e.Status.Tint(e.baseColor)
+}
+
+func (e *enemy) Stunned() bool {
+	return e.Status.Active(StatusStun)
+}
+
+// updateStatus runs the status effects of the enemy: it slides along the
+// knockback, takes damage over time and tints the sprite. It returns false
+// when the enemy has died from its wounds.
+func (e *enemy) updateStatus() bool {
+	damage, push := e.Status.Tick()
+	if push != pixel.ZV {
+		e.move(push.Scaled(engine.dt))
+	}
+	e.Color = e.Status.Tint(e.baseColor)
+	e.wounds += damage
+	if e.wounds >= 1 {
+		e.Kill()
+		return false
+	}
+	return true
+}
+
+// move shifts the enemy by delta unless it runs into a wall.
+// It returns false when the wall is in the way.
+func (e *enemy) move(delta pixel.Vec) bool {
+	c := e.AbsCollider().Moved(delta)
+	for _, wall := range world.GetColliders(c) {
+		if collides(c, wall) {
+			return false
+		}
+	}
+	e.Pos = e.Pos.Add(delta)
+	return true
+}
+
+// steer bends the desired direction dir of the enemy by the flocking rules of
+// its kind, so crowds spread around the hero instead of merging into one blob.
+func (e *enemy) steer(near *Nearby, dir pixel.Vec) pixel.Vec {
+	f := enemyFlocking[e.kind]
+	if len(near.Neighbors) == 0 || f.Radius == 0 {
+		return dir
+	}
+	var sep, vel, center pixel.Vec
+	for _, n := range near.Neighbors {
+		away := e.Pos.Sub(n.Pos)
+		d := away.Len()
+		if d == 0 {
+			// Enemies on top of each other pick a random side.
+			away = pixel.Unit(rand.Float64() * 2 * math.Pi)
+		}
+		// Push is the strongest when enemies overlap and fades to zero at the radius.
+		sep = sep.Add(away.Unit().Scaled(math.Max(0, f.Radius-d) / f.Radius))
+		vel = vel.Add(n.Vel)
+		center = center.Add(n.Pos)
+	}
+	k := float64(len(near.Neighbors))
+	res := dir.Add(sep.Scaled(f.Separation))
+	if v := vel.Scaled(1 / k); v.Len() > 0 {
+		res = res.Add(v.Unit().Scaled(f.Alignment))
+	}
+	if c := center.Scaled(1 / k).Sub(e.Pos); c.Len() > 0 {
+		res = res.Add(c.Unit().Scaled(f.Cohesion))
+	}
+	if res.Len() == 0 {
+		return dir
 	}
-	s.Pos = p
+	return res.Unit()
+}
+
+// touchHero hurts the hero touching the enemy by the contact model of the
+// kind: it drains drainRate health per second or hits the hero once.
+// Immune hero slips through.
+func (e *enemy) touchHero(near *Nearby, drainRate float64) bool {
+	if !near.Hero || hero.Immune() || !collides(e.AbsCollider(), hero.AbsCollider()) {
+		return false
+	}
+	if c := tuning.Contact[e.kind]; c.Model == ContactHit {
+		return hero.Hit(c.Damage, hero.Pos.Sub(e.Pos).Unit().Scaled(c.Knockback))
+	}
+	hero.Damage(-drainRate * engine.dt)
+	hero.Status.Apply(StatusSlow, tuning.Hero.DrainSlow, 0.3)
+	return true
+}
+
+// hitBy kills the enemy if one of the nearby arrows hits it and returns that arrow.
+func (e *enemy) hitBy(near *Nearby) *Arrow {
+	col := e.AbsCollider()
+	for _, arrow := range near.Arrows {
+		if arrow.Kills(col) {
+			difficulty.Hit()
+			e.Kill()
+			arrow.Hit()
+			return arrow
+		}
+	}
+	return nil
+}
+
+type Slime struct {
+	enemy
+	mover
+	speed       float64
+	speedFactor float64
+	drainRate   float64
+	rotation    float64
+}
+
+func NewSlime(spr *pixel.Sprite) *Slime {
+	sl := &Slime{enemy: newEnemy(spr, EnemySlime, colornames.Red, 1)}
+	sl.speed = tuning.Slime.Speed
+	sl.speedFactor = 1
+	sl.drainRate = tuning.Slime.Drain
+	return sl
+}
+
+// NewSlimeling creates a small and fast slime, a remnant of the big one.
+func NewSlimeling(spr *pixel.Sprite) *Slime {
+	sl := &Slime{enemy: newEnemy(spr, EnemySlimeling, colornames.Tomato, 0.65)}
+	sl.speed = tuning.Slime.Speed
+	sl.speedFactor = tuning.Slimeling.SpeedFactor
+	sl.drainRate = tuning.Slimeling.Drain
+	return sl
+}
+
+func (s *Slime) Spawn(pos pixel.Vec) {
+	s.spawn(pos)
+	s.reset(enemyMovement[s.kind])
 	s.rotation = rand.Float64() + 0.2
-	s.speed = s.rotation*40 + 30 + engine.elapsed/5
-	// s.speed /= 1000
-	s.Active = true
-	s.Visible = true
-	s.Alive = true
+	s.speed = (s.rotation*tuning.Slime.Speed + tuning.Slime.BaseSpeed + engine.elapsed*tuning.Slime.Speedup) * s.speedFactor * difficulty.Speed()
+}
+
+// SetMovement replaces the default movement of the kind until the next spawn.
+func (s *Slime) SetMovement(m Movement) {
+	s.reset(m)
 }
 
-func (s *Slime) Kill() {
-	s.Alive = false
-	s.Color = colornames.Grey
+func (s *Slime) Stun(duration float64, knockback pixel.Vec) {
+	s.enemy.Stun(duration, knockback)
+	s.fixed = false
 }
 
-func (s *Slime) Update(arrows []*Arrow) {
+func (s *Slime) Update(near *Nearby) *Arrow {
 	// Slimes should "see" the player and fly to touch the player.
-	// TODO: Implement spiralled movement.
-	if !s.Alive || !s.Active {
-		return
+	if !s.alive || !s.Active {
+		return nil
 	}
 
-	var dir pixel.Vec
-	dir = hero.Pos.Sub(s.Pos).Unit()
-	if s.fixed {
-		// Slime sticks to some constant directing until it goes out of range.
-		dir = s.fixedDirection.Add(dir.Scaled(0.5)).Unit()
+	if !s.updateStatus() {
+		return nil
 	}
-	delta := dir.Scaled(s.speed * engine.dt)
-	s.Pos = s.Pos.Add(delta)
+	if s.Stunned() {
+		return s.hitBy(near)
+	}
+
+	dir, factor := s.direction(s.Pos)
+	dir = s.steer(near, dir)
+	delta := dir.Scaled(s.speed * factor * s.Status.SpeedFactor() * engine.dt)
 	s.Angle += (s.rotation + 0.2) * engine.dt
 
-	wallCollided := false
-	wcol := s.AbsCollider()
-	if collides(wcol, hero.AbsCollider()) {
-		hero.Damage(-s.drainRate * engine.dt)
-		hero.SlowDown(0.7)
-	} else {
-		colWorld := s.AbsCollider()
-		walls := world.GetColliders(colWorld)
-		c := colWorld.Moved(delta)
-		for _, wall := range walls {
-			if collides(c, wall) {
-				s.Pos = s.Pos.Sub(delta)
-				wallCollided = true
-				if s.fixed {
-					s.fixed = false
-				}
-			}
-		}
+	if s.touchHero(near, s.drainRate) {
+		s.Pos = s.Pos.Add(delta)
+	} else if !s.move(delta) {
+		s.blocked()
 	}
 
-	diff := hero.Pos.Sub(s.Pos).Len()
-	if diff <= 92 && !wallCollided {
-		if diff < 48 && !s.fixed {
-			s.fixedDirection = dir
-			s.fixed = true
-		}
-		rate := (42 - diff) / 92
-		// Speed up when diff==92 and then slow down when diff < 32
-		s.Pos = s.Pos.Sub(delta.Scaled(rate))
-	} else if s.fixed {
-		s.fixed = false
+	if diff := hero.Pos.Sub(s.Pos).Len(); diff < lungeRange {
+		s.Angle += (lungeRange - diff) / 300
+	}
+
+	return s.hitBy(near)
+}
+
+// BigSlime is a slow and heavy slime which splits into two slimelings on death.
+type BigSlime struct {
+	Slime
+}
+
+func NewBigSlime(spr *pixel.Sprite) *BigSlime {
+	b := &BigSlime{}
+	b.enemy = newEnemy(spr, EnemyBigSlime, colornames.Firebrick, 1.6)
+	b.speed = tuning.Slime.Speed
+	b.speedFactor = tuning.BigSlime.SpeedFactor
+	b.drainRate = tuning.BigSlime.Drain
+	return b
+}
+
+func (b *BigSlime) SplitInto() []EnemyKind {
+	return []EnemyKind{EnemySlimeling, EnemySlimeling}
+}
+
+// Spitter keeps its distance from the hero and spits slime at it.
+type Spitter struct {
+	enemy
+	patterned
+	speed     float64
+	drainRate float64
+	minRange  float64 // spitter backs off when the hero is closer than this
+	maxRange  float64 // spitter approaches when the hero is further than this
+	strafe    float64 // direction of circling around the hero, 1 or -1
+	nextSpit  float64
+	spitEvery float64
+	spitSpeed float64
+	spitCount int // spits in one volley, more than one are spread in a fan
+}
+
+func NewSpitter(spr *pixel.Sprite) *Spitter {
+	sp := &Spitter{enemy: newEnemy(spr, EnemySpitter, colornames.Yellowgreen, 1)}
+	sp.speed = tuning.Spitter.Speed
+	sp.drainRate = tuning.Spitter.Drain
+	sp.minRange = 70
+	sp.maxRange = 110
+	sp.spitEvery = 2.5
+	sp.spitSpeed = 90
+	sp.spitCount = 1
+	return sp
+}
+
+func (sp *Spitter) Spawn(pos pixel.Vec) {
+	sp.spawn(pos)
+	sp.on = false
+	sp.speed = tuning.Spitter.Speed + engine.elapsed*tuning.Spitter.Speedup
+	sp.strafe = 1
+	if rand.Intn(2) == 0 {
+		sp.strafe = -1
+	}
+	sp.nextSpit = engine.elapsed + sp.spitEvery
+	// Older spitters spit in fans.
+	sp.spitCount = 1 + 2*int(engine.elapsed/240)
+	if sp.spitCount > 5 {
+		sp.spitCount = 5
+	}
+}
+
+func (sp *Spitter) Update(near *Nearby) *Arrow {
+	if !sp.alive || !sp.Active {
+		return nil
+	}
+
+	if !sp.updateStatus() {
+		return nil
+	}
+	if sp.Stunned() {
+		return sp.hitBy(near)
+	}
+
+	toHero := hero.Pos.Sub(sp.Pos)
+	dist := toHero.Len()
+	dir, factor := toHero.Unit(), 1.0
+	switch {
+	case sp.on:
+		dir, factor = sp.direction(sp.Pos)
+	case dist < sp.minRange:
+		dir = dir.Scaled(-1)
+	case dist < sp.maxRange:
+		dir = dir.Normal().Scaled(sp.strafe)
+	}
+	dir = sp.steer(near, dir)
+	if !sp.move(dir.Scaled(sp.speed * factor * sp.Status.SpeedFactor() * engine.dt)) {
+		sp.strafe = -sp.strafe
+		sp.blocked()
+	}
+	sp.Angle = toHero.Angle() - math.Pi/2
+	sp.touchHero(near, sp.drainRate)
+
+	if engine.elapsed > sp.nextSpit && dist < sp.maxRange*1.5 {
+		sp.nextSpit = engine.elapsed + sp.spitEvery
+		projectiles.Spread(sp.Pos, hero.Pos, sp.spitCount, math.Pi/5, sp.spitSpeed, 12, colornames.Greenyellow)
 	}
 
-	if diff < 92 {
-		rate := (92 - diff) / 300
-		s.Angle += rate
+	return sp.hitBy(near)
+}
+
+// Beetle is armored: arrows bounce off its front, so it dies only from shots
+// in the back or from charged arrows.
+type Beetle struct {
+	enemy
+	patterned
+	speed     float64
+	turnRate  float64 // radians per second
+	drainRate float64
+	facing    pixel.Vec
+}
+
+func NewBeetle(spr *pixel.Sprite) *Beetle {
+	b := &Beetle{enemy: newEnemy(spr, EnemyBeetle, colornames.Darkkhaki, 1.1)}
+	b.speed = tuning.Beetle.Speed
+	b.turnRate = 1.6
+	b.drainRate = tuning.Beetle.Drain
+	return b
+}
+
+func (b *Beetle) Spawn(pos pixel.Vec) {
+	b.spawn(pos)
+	b.on = false
+	b.facing = hero.Pos.Sub(pos).Unit()
+	b.speed = tuning.Beetle.Speed + engine.elapsed*tuning.Beetle.Speedup
+}
+
+func (b *Beetle) Update(near *Nearby) *Arrow {
+	if !b.alive || !b.Active {
+		return nil
+	}
+
+	if !b.updateStatus() {
+		return nil
 	}
+	if !b.Stunned() {
+		// The beetle turns slowly, so the hero can outrun it and shoot its back.
+		want, factor := hero.Pos.Sub(b.Pos), 1.0
+		if b.on {
+			want, factor = b.direction(b.Pos)
+		}
+		cur := b.facing.Angle()
+		turn := math.Remainder(want.Angle()-cur, 2*math.Pi)
+		maxTurn := b.turnRate * b.Status.SpeedFactor() * engine.dt
+		turn = pixel.Clamp(turn, -maxTurn, maxTurn)
+		b.facing = pixel.Unit(cur + turn)
+		if !b.move(b.steer(near, b.facing).Scaled(b.speed * factor * b.Status.SpeedFactor() * engine.dt)) {
+			b.blocked()
+		}
+		b.touchHero(near, b.drainRate)
+	}
+	b.Angle = b.facing.Angle() - math.Pi/2
 
-	for _, arrow := range arrows {
-		if arrow.Kills(wcol) {
-			s.Kill()
-			arrow.Stick()
-			break
+	col := b.AbsCollider()
+	for _, arrow := range near.Arrows {
+		if !arrow.Kills(col) {
+			continue
+		}
+		difficulty.Hit()
+		// Arrow flying in the same direction as the beetle faces hits its back.
+		fromBehind := angleBetween(arrow.Velocity(), b.facing) < math.Pi/2
+		if fromBehind || arrow.Damage > 1 {
+			b.Kill()
+			arrow.Hit()
+			return arrow
 		}
+		// Armor stops the arrow but not the fire.
+		arrow.Afflict(&b.Status)
+		arrow.Stick()
 	}
+	return nil
 }
 
-func firstFreeSlime(s []*Slime) int {
-	free := -1
-	for i := range s {
-		if !s[i].Active {
-			free = i
-			break
+// Necromancer keeps away from the hero and raises corpses around itself.
+type Necromancer struct {
+	enemy
+	patterned
+	speed       float64
+	drainRate   float64
+	keepAway    float64 // necromancer backs off when the hero is closer than this
+	raiseEvery  float64
+	raiseRadius float64
+	raiseCount  int
+	nextRaise   float64
+	castEnd     float64
+	casting     bool
+	raise       bool
+}
+
+// The necromancer stands still and glows for this many seconds before raising corpses.
+const necromancerCastTime = 1.2
+
+func NewNecromancer(spr *pixel.Sprite) *Necromancer {
+	n := &Necromancer{enemy: newEnemy(spr, EnemyNecromancer, colornames.Mediumpurple, 1)}
+	n.speed = tuning.Necromancer.Speed
+	n.drainRate = tuning.Necromancer.Drain
+	n.keepAway = 90
+	n.raiseEvery = 6
+	n.raiseRadius = 60
+	n.raiseCount = 3
+	return n
+}
+
+func (n *Necromancer) Spawn(pos pixel.Vec) {
+	n.spawn(pos)
+	n.on = false
+	n.speed = tuning.Necromancer.Speed + engine.elapsed*tuning.Necromancer.Speedup
+	n.nextRaise = engine.elapsed + n.raiseEvery/2
+	n.casting = false
+	n.raise = false
+}
+
+func (n *Necromancer) Stun(duration float64, knockback pixel.Vec) {
+	n.enemy.Stun(duration, knockback)
+	n.casting = false
+}
+
+func (n *Necromancer) Reanimate() (float64, int) {
+	if !n.raise {
+		return 0, 0
+	}
+	n.raise = false
+	return n.raiseRadius, n.raiseCount
+}
+
+func (n *Necromancer) Update(near *Nearby) *Arrow {
+	if !n.alive || !n.Active {
+		return nil
+	}
+
+	if !n.updateStatus() {
+		return nil
+	}
+	if n.Stunned() {
+		return n.hitBy(near)
+	}
+
+	switch {
+	case n.casting:
+		n.Color = colornames.White
+		if int(engine.elapsed*10)%2 == 0 {
+			n.Color = colornames.Mediumpurple
+		}
+		if engine.elapsed > n.castEnd {
+			n.casting = false
+			n.raise = true
+			n.Color = n.baseColor
+			n.nextRaise = engine.elapsed + n.raiseEvery
+		}
+	case engine.elapsed > n.nextRaise:
+		n.casting = true
+		n.castEnd = engine.elapsed + necromancerCastTime
+	default:
+		toHero := hero.Pos.Sub(n.Pos)
+		dir, factor := toHero.Unit(), 1.0
+		if n.on {
+			dir, factor = n.direction(n.Pos)
+		} else if toHero.Len() < n.keepAway {
+			dir = dir.Scaled(-1)
+		}
+		if !n.move(n.steer(near, dir).Scaled(n.speed * factor * n.Status.SpeedFactor() * engine.dt)) {
+			n.blocked()
 		}
 	}
-	return free
+	n.touchHero(near, n.drainRate)
+
+	return n.hitBy(near)
 }
@@ -13,6 +13,8 @@ type Engine struct {
 	dt               float64
 	win              *pixelgl.Window
 	winCfg           *pixelgl.WindowConfig
+	paused           bool
+	pausedAt         time.Time
 	// fpsCounter       int
 	// fpsTicker        <-chan time.Time
 }
@@ -33,5 +35,27 @@ func NewEngine(cfg *pixelgl.WindowConfig) *Engine {
 }
 
 func (e *Engine) fpsHandler() {
+	if e.paused {
+		return
+	}
 	e.elapsed = time.Since(e.started).Seconds()
 }
+
+// Pause stops the game clock, elapsed does not change until Resume is called.
+func (e *Engine) Pause() {
+	if !e.paused {
+		e.paused = true
+		e.pausedAt = time.Now()
+	}
+}
+
+func (e *Engine) Resume() {
+	if e.paused {
+		e.paused = false
+		e.started = e.started.Add(time.Since(e.pausedAt))
+	}
+}
+
+func (e *Engine) Paused() bool {
+	return e.paused
+}
@@ -1,7 +1,11 @@
 package main
 
 import (
+	"image/color"
+	"math"
+
 	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
 	"github.com/faiface/pixel/pixelgl"
 	"golang.org/x/image/colornames"
 )
@@ -12,22 +16,62 @@ type Hero struct {
 	maxVel            float64
 	accel             float64
 	health, maxHealth float64
+	diedAt            float64
+	Status            Statuses
+
+	// Dash is a short burst of speed which makes the hero immune to drain.
+	DashSpeed    float64
+	DashTime     float64 // seconds of the burst
+	DashCooldown float64 // seconds from the start of one dash to the next one
+	ImmuneTime   float64 // seconds of immunity from the start of the dash
+	// Enemies which hit instead of draining leave the hero invulnerable for
+	// HitImmuneTime seconds.
+	HitImmuneTime float64
+	flashEnd      float64
+	dashDir       pixel.Vec
+	dashEnd       float64
+	nextDash      float64
+	immuneEnd     float64
+	trail         []trailPoint
+
+	Buffs   Buffs
+	Stamina Stamina
+
+	Class       *HeroClass
+	Tint        color.RGBA // mixed into the color of the health, zero keeps it
+	nextAbility float64
+	stompAt     float64
+}
+
+type trailPoint struct {
+	pos pixel.Vec
+	at  float64
 }
 
+// Ghosts of the dash trail fade out in this many seconds.
+const trailTime = 0.25
+
 func NewHero(s *pixel.Sprite, pos pixel.Vec, maxVel, accel float64) *Hero {
 	e := NewEntity(s, pos)
 	return &Hero{
-		Entity:    *e,
-		maxVel:    maxVel,
-		accel:     accel,
-		maxHealth: 100,
-		health:    100,
+		Entity:       *e,
+		maxVel:       maxVel,
+		accel:        accel,
+		maxHealth:    100,
+		health:       100,
+		DashSpeed:    260,
+		DashTime:     0.18,
+		DashCooldown: 0.9,
+		ImmuneTime:   0.3,
 	}
 }
 
 func (h *Hero) Damage(health float64) {
 	if h.Alive() {
 		h.health = pixel.Clamp(h.health+health, 0, h.maxHealth)
+		if !h.Alive() {
+			h.diedAt = engine.elapsed
+		}
 	}
 }
 
@@ -39,6 +83,92 @@ func (h *Hero) Alive() bool {
 	return h.health > 0
 }
 
+// Dash rushes the hero in direction dir. It returns false while the dash
+// is cooling down or the hero can not move.
+func (h *Hero) Dash(dir pixel.Vec) bool {
+	if !h.Alive() || engine.elapsed < h.nextDash || dir == pixel.ZV || h.Status.Active(StatusStun) {
+		return false
+	}
+	if !h.Stamina.Spend(h.Stamina.Dash) {
+		return false
+	}
+	h.dashDir = dir.Unit()
+	h.dashEnd = engine.elapsed + h.DashTime
+	h.nextDash = engine.elapsed + h.DashCooldown
+	h.immuneEnd = engine.elapsed + h.ImmuneTime
+	return true
+}
+
+func (h *Hero) Dashing() bool {
+	return engine.elapsed < h.dashEnd
+}
+
+// Immune returns true while enemies can not hurt the hero by touch.
+func (h *Hero) Immune() bool {
+	return engine.elapsed < h.immuneEnd
+}
+
+// Hit takes damage health at once and throws the hero with velocity knock.
+// The hero flashes and can not be hit again for HitImmuneTime seconds.
+func (h *Hero) Hit(damage float64, knock pixel.Vec) bool {
+	if !h.Alive() || h.Immune() {
+		return false
+	}
+	h.Damage(-damage)
+	h.Status.Knock(knock)
+	h.immuneEnd = engine.elapsed + h.HitImmuneTime
+	h.flashEnd = h.immuneEnd
+	return true
+}
+
+// DashReady returns the part of the cooldown which has passed, 1 when the
+// hero can dash again.
+func (h *Hero) DashReady() float64 {
+	if h.DashCooldown <= 0 {
+		return 1
+	}
+	return pixel.Clamp(1-(h.nextDash-engine.elapsed)/h.DashCooldown, 0, 1)
+}
+
+// DrawDashCooldown draws a thin bar above the hero while the dash cools down.
+func (h *Hero) DrawDashCooldown(imd *imdraw.IMDraw) {
+	ready := h.DashReady()
+	if ready >= 1 || !h.Alive() {
+		return
+	}
+	const w = 10.0
+	min := h.Pos.Add(pixel.V(-w/2, 9))
+	imd.Color = colornames.Lightcyan
+	imd.Push(min, min.Add(pixel.V(w*ready, 1)))
+	imd.Rectangle(0)
+}
+
+// DrawTrail draws fading ghosts of the hero along the path of the dash.
+func (h *Hero) DrawTrail(t pixel.Target) {
+	for _, p := range h.trail {
+		alpha := 0.5 * (1 - (engine.elapsed-p.at)/trailTime)
+		if alpha <= 0 {
+			continue
+		}
+		m := pixel.IM.ScaledXY(pixel.ZV, h.ScaleXY).Moved(p.pos)
+		h.Sprite.DrawColorMask(t, m, fade(colornames.Lightcyan, alpha))
+	}
+}
+
+func (h *Hero) updateTrail() {
+	n := 0
+	for _, p := range h.trail {
+		if engine.elapsed-p.at < trailTime {
+			h.trail[n] = p
+			n++
+		}
+	}
+	h.trail = h.trail[:n]
+	if h.Dashing() {
+		h.trail = append(h.trail, trailPoint{h.Pos, engine.elapsed})
+	}
+}
+
 func (h *Hero) Update() {
 	pct := h.health / h.maxHealth * 100
 	switch {
@@ -55,17 +185,37 @@ func (h *Hero) Update() {
 	default:
 		h.Color = colornames.Purple
 	}
+	if h.Tint.A != 0 {
+		h.Color = mix(h.Color, h.Tint, 0.5)
+	}
+	h.Visible = engine.elapsed >= h.flashEnd || math.Sin(engine.elapsed*40) > 0
 
 	if !h.Alive() {
+		h.animateDeath()
 		return
 	}
 
-	daccel := h.accel * engine.dt
+	damage, push := h.Status.Tick()
+	h.Damage(-damage)
+	h.Color = h.Status.Tint(h.Color)
+	// Stunned hero does not listen to the keys.
+	stunned := h.Status.Active(StatusStun)
+	maxVel := h.Buffs.Stat(StatMaxVel, h.maxVel) * h.Status.SpeedFactor() * h.Stamina.SpeedFactor()
+	win := engine.win
+	running := !stunned && (win.Pressed(pixelgl.KeyA) || win.Pressed(pixelgl.KeyD) || win.Pressed(pixelgl.KeyW) || win.Pressed(pixelgl.KeyS))
+	if running && win.Pressed(pixelgl.KeyLeftShift) && h.Stamina.Drain(h.Stamina.Sprint) {
+		maxVel *= h.Stamina.SprintSpeed
+	}
+	if !running && h.velocity == pixel.ZV && !h.Dashing() {
+		h.Stamina.Regenerate()
+	}
+
+	daccel := h.Buffs.Stat(StatAccel, h.accel) * engine.dt
 
 	dx := 0.0
-	if engine.win.Pressed(pixelgl.KeyA) {
+	if !stunned && engine.win.Pressed(pixelgl.KeyA) {
 		dx = -daccel
-	} else if engine.win.Pressed(pixelgl.KeyD) {
+	} else if !stunned && engine.win.Pressed(pixelgl.KeyD) {
 		dx = +daccel
 	} else {
 		// handle deceleration correctly, don't let it oscilate around 0.
@@ -77,12 +227,12 @@ func (h *Hero) Update() {
 			h.velocity.X = 0
 		}
 	}
-	h.velocity.X = pixel.Clamp(h.velocity.X+dx, -h.maxVel, h.maxVel)
+	h.velocity.X = pixel.Clamp(h.velocity.X+dx, -maxVel, maxVel)
 
 	dy := 0.0
-	if engine.win.Pressed(pixelgl.KeyS) {
+	if !stunned && engine.win.Pressed(pixelgl.KeyS) {
 		dy = -daccel
-	} else if engine.win.Pressed(pixelgl.KeyW) {
+	} else if !stunned && engine.win.Pressed(pixelgl.KeyW) {
 		dy = +daccel
 	} else {
 		if h.velocity.Y >= daccel {
@@ -93,15 +243,20 @@ func (h *Hero) Update() {
 			h.velocity.Y = 0
 		}
 	}
-	h.velocity.Y = pixel.Clamp(h.velocity.Y+dy, -h.maxVel, h.maxVel)
+	h.velocity.Y = pixel.Clamp(h.velocity.Y+dy, -maxVel, maxVel)
 
 	// limit diagonal speed
 	actualVel := h.velocity.Len()
-	if actualVel > h.maxVel {
-		h.velocity = h.velocity.Scaled(h.maxVel / actualVel)
+	if actualVel > maxVel {
+		h.velocity = h.velocity.Scaled(maxVel / actualVel)
+	}
+
+	if h.Dashing() {
+		h.velocity = h.dashDir.Scaled(h.DashSpeed)
 	}
+	h.updateTrail()
 
-	delta := h.velocity.Scaled(engine.dt)
+	delta := h.velocity.Add(push).Scaled(engine.dt)
 
 	colWorld := h.AbsCollider()
 	walls := world.GetColliders(colWorld)
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/faiface/pixel"
+)
+
+// EnemySprites holds the sprites used by all kinds of enemies.
+type EnemySprites struct {
+	Slime       *pixel.Sprite
+	Spitter     *pixel.Sprite
+	Beetle      *pixel.Sprite
+	Necromancer *pixel.Sprite
+	Crown       *pixel.Sprite
+}
+
+// Horde is the pool of all enemies in the world. Dead enemies stay in the pool
+// as corpses until they decay by Corpses rules or their slot is needed for
+// a new enemy.
+type Horde struct {
+	enemies []Enemy
+	max     int
+	recycle int
+	sprites EnemySprites
+	Corpses CorpseRules
+	diedAt  []float64 // when the enemy died, by slot
+	loot    []bool    // whether the corpse holds an arrow, by slot
+
+	// Broadphase grids, rebuilt on every Update. Enemies are stored by
+	// their slot in enemies, arrows by their index in the arrows slice.
+	enemyGrid *SpatialGrid
+	arrowGrid *SpatialGrid
+	touching  []bool      // enemies which may touch the hero, by slot
+	vel       []pixel.Vec // velocity of enemies during the last tick, by slot
+	near      Nearby
+	ids       []int
+}
+
+const (
+	gridCellSize = 32
+	// Things move a bit during the tick, so the broadphase looks slightly
+	// further than the colliders reach.
+	broadphaseMargin = 4
+)
+
+func NewHorde(max int, sprites EnemySprites) *Horde {
+	bounds := pixel.R(0, 0, float64(world.width*world.gridSize), float64(world.height*world.gridSize))
+	return &Horde{
+		enemies:   make([]Enemy, 0, max),
+		max:       max,
+		sprites:   sprites,
+		enemyGrid: NewSpatialGrid(bounds, gridCellSize),
+		arrowGrid: NewSpatialGrid(bounds, gridCellSize),
+		touching:  make([]bool, max),
+		vel:       make([]pixel.Vec, max),
+		diedAt:    make([]float64, max),
+		loot:      make([]bool, max),
+	}
+}
+
+func (h *Horde) Enemies() []Enemy {
+	return h.enemies
+}
+
+func (h *Horde) newEnemy(kind EnemyKind) Enemy {
+	switch kind {
+	case EnemySlimeling:
+		return NewSlimeling(h.sprites.Slime)
+	case EnemyBigSlime:
+		return NewBigSlime(h.sprites.Slime)
+	case EnemySpitter:
+		return NewSpitter(h.sprites.Spitter)
+	case EnemyBeetle:
+		return NewBeetle(h.sprites.Beetle)
+	case EnemyNecromancer:
+		return NewNecromancer(h.sprites.Necromancer)
+	case EnemyBoss:
+		return NewBoss(h.sprites.Slime, h.sprites.Crown)
+	default:
+		return NewSlime(h.sprites.Slime)
+	}
+}
+
+// Spawn brings an enemy of the kind to life at a random spot far enough from the hero.
+func (h *Horde) Spawn(kind EnemyKind) Enemy {
+	return h.SpawnAt(kind, farSpot())
+}
+
+// Spots closer to the hero than tuning.SpawnExclusion are tried again up to
+// this many times, then the furthest one is taken.
+const spotTries = 64
+
+// farSpot returns a random spot in the world not closer than tuning.SpawnExclusion to the hero.
+func farSpot() pixel.Vec {
+	return furthestSpot(world.RandomVec)
+}
+
+// furthestSpot returns the first spot from random which is far enough from
+// the hero, or the furthest one out of spotTries.
+func furthestSpot(random func() pixel.Vec) pixel.Vec {
+	best, bestDist := pixel.ZV, -1.0
+	for i := 0; i < spotTries; i++ {
+		p := random()
+		dist := hero.Pos.Sub(p).Len()
+		if dist >= tuning.SpawnExclusion {
+			return p
+		}
+		if dist > bestDist {
+			best, bestDist = p, dist
+		}
+	}
+	return best
+}
+
+// Boss returns the living boss or nil.
+func (h *Horde) Boss() *Boss {
+	for _, e := range h.enemies {
+		if b, ok := e.(*Boss); ok && b.Alive() {
+			return b
+		}
+	}
+	return nil
+}
+
+// AliveCount returns the number of living enemies.
+func (h *Horde) AliveCount() int {
+	n := 0
+	for _, e := range h.enemies {
+		if e.Alive() {
+			n++
+		}
+	}
+	return n
+}
+
+// SpawnAt brings an enemy of the kind to life at pos, moved away from the
+// walls when its collider would stick into them. Free slots of the pool are
+// reused first, then new enemies are allocated until the pool is full, and
+// after that the oldest slots are recycled no matter what occupies them.
+func (h *Horde) SpawnAt(kind EnemyKind, pos pixel.Vec) Enemy {
+	var e Enemy
+	if free := h.firstFree(kind); free != -1 {
+		e = h.enemies[free]
+	} else if len(h.enemies) < h.max {
+		e = h.newEnemy(kind)
+		h.enemies = append(h.enemies, e)
+	} else {
+		// Never recycle the living boss.
+		if b := h.enemies[h.recycle]; b.Kind() == EnemyBoss && b.Alive() {
+			h.recycle = (h.recycle + 1) % len(h.enemies)
+		}
+		if h.enemies[h.recycle].Kind() != kind {
+			h.enemies[h.recycle] = h.newEnemy(kind)
+		}
+		e = h.enemies[h.recycle]
+		h.recycle++
+		if h.recycle >= len(h.enemies) {
+			h.recycle = 0
+		}
+	}
+	e.Spawn(world.Fit(pos, *e.Body().Collider))
+	return e
+}
+
+func (h *Horde) firstFree(kind EnemyKind) int {
+	for i, e := range h.enemies {
+		if !e.Body().Active && e.Kind() == kind {
+			return i
+		}
+	}
+	return -1
+}
+
+// rebuildGrids fills the broadphase grids with living enemies and flying arrows
+// and finds the enemies which may touch the hero.
+func (h *Horde) rebuildGrids(arrows []*Arrow) {
+	h.enemyGrid.Clear()
+	for i, e := range h.enemies {
+		h.touching[i] = false
+		if e.Alive() {
+			h.enemyGrid.Insert(i, e.AbsCollider())
+		}
+	}
+	h.arrowGrid.Clear()
+	for i, a := range arrows {
+		if a.State == ArrowFlying {
+			h.arrowGrid.Insert(i, a.AbsCollider())
+		}
+	}
+	h.ids = h.enemyGrid.QueryRect(expand(hero.AbsCollider(), broadphaseMargin), h.ids[:0])
+	for _, id := range h.ids {
+		h.touching[id] = true
+	}
+}
+
+func expand(r pixel.Rect, d float64) pixel.Rect {
+	return pixel.R(r.Min.X-d, r.Min.Y-d, r.Max.X+d, r.Max.Y+d)
+}
+
+// Update updates all enemies and calls onKill for every enemy which died
+// during this update together with the arrow which killed it, if any.
+// Splitters leave their offspring where they died.
+func (h *Horde) Update(arrows []*Arrow, onKill func(e Enemy, killer *Arrow)) {
+	h.rebuildGrids(arrows)
+	// Enemies spawned by splitters are appended to the pool and should not be
+	// updated in the same frame.
+	n := len(h.enemies)
+	for i := 0; i < n; i++ {
+		e := h.enemies[i]
+		if !e.Body().Active {
+			continue
+		}
+		aliveBefore := e.Alive()
+		h.near.Hero = h.touching[i]
+		h.near.Arrows = h.near.Arrows[:0]
+		h.near.Neighbors = h.near.Neighbors[:0]
+		pos := e.Body().Pos
+		if aliveBefore {
+			h.ids = h.arrowGrid.QueryRect(expand(e.AbsCollider(), broadphaseMargin), h.ids[:0])
+			for _, id := range h.ids {
+				h.near.Arrows = append(h.near.Arrows, arrows[id])
+			}
+			if r := enemyFlocking[e.Kind()].Radius; r > 0 {
+				h.ids = h.enemyGrid.QueryRadius(pos, r, h.ids[:0])
+				for _, id := range h.ids {
+					if id != i {
+						h.near.Neighbors = append(h.near.Neighbors, Neighbor{h.enemies[id].Body().Pos, h.vel[id]})
+					}
+				}
+			}
+		}
+		killer := e.Update(&h.near)
+		if engine.dt > 0 {
+			h.vel[i] = e.Body().Pos.Sub(pos).Scaled(1 / engine.dt)
+		}
+		if sm, ok := e.(Summoner); ok && e.Alive() {
+			pos := e.Body().Pos
+			for _, kind := range sm.Summon() {
+				offset := pixel.Unit(rand.Float64() * 2 * math.Pi).Scaled(28 + rand.Float64()*12)
+				h.SpawnAt(kind, world.ClampVec(pos.Add(offset)))
+			}
+		}
+		if rn, ok := e.(Reanimator); ok && e.Alive() {
+			if radius, n := rn.Reanimate(); n > 0 {
+				h.reanimate(e.Body().Pos, radius, n)
+			}
+		}
+		if !aliveBefore || e.Alive() {
+			continue
+		}
+		h.corpseDied(i)
+		if onKill != nil {
+			onKill(e, killer)
+		}
+		if sp, ok := e.(Splitter); ok {
+			pos := e.Body().Pos
+			for _, kind := range sp.SplitInto() {
+				offset := pixel.V(rand.Float64()-0.5, rand.Float64()-0.5).Scaled(12)
+				child := h.SpawnAt(kind, pos.Add(offset))
+				child.Stun(0.4, offset.Unit().Scaled(120))
+			}
+		}
+	}
+	for _, a := range arrows {
+		if a.bouncing {
+			h.ricochet(a)
+		}
+	}
+}
+
+// ricochet sends the bouncing arrow to the nearest living enemy, or lets it
+// stick when there is none.
+func (h *Horde) ricochet(a *Arrow) {
+	a.bouncing = false
+	e := h.Nearest(a.Pos, tuning.Arrow.RicochetRange, func(e Enemy) bool {
+		return e.Body().Pos.Sub(a.Pos).Len() > 1
+	})
+	if e == nil {
+		a.Stick()
+		return
+	}
+	a.Ricochet(e.Body().Pos)
+}
+
+// Near appends to buf living enemies within radius from pos, as they were
+// at the last Update.
+func (h *Horde) Near(pos pixel.Vec, radius float64, buf []Enemy) []Enemy {
+	h.ids = h.enemyGrid.QueryRadius(pos, radius, h.ids[:0])
+	for _, id := range h.ids {
+		if e := h.enemies[id]; e.Alive() {
+			buf = append(buf, e)
+		}
+	}
+	return buf
+}
+
+// Nearest returns the living enemy closest to pos within radius for which
+// accept returns true, or nil.
+func (h *Horde) Nearest(pos pixel.Vec, radius float64, accept func(e Enemy) bool) Enemy {
+	id := h.enemyGrid.Nearest(pos, radius, func(id int) bool {
+		e := h.enemies[id]
+		return e.Alive() && (accept == nil || accept(e))
+	})
+	if id == -1 {
+		return nil
+	}
+	return h.enemies[id]
+}
+
+// DrawCorpses draws dead enemies, they should be below everything else.
+func (h *Horde) DrawCorpses(t pixel.Target) {
+	for _, e := range h.enemies {
+		if !e.Alive() {
+			e.Draw(t)
+		}
+	}
+}
+
+func (h *Horde) DrawAlive(t pixel.Target) {
+	for _, e := range h.enemies {
+		if e.Alive() {
+			e.Draw(t)
+		}
+	}
+}
+
+// RandomEnemyKind picks the kind of the next enemy. Tougher enemies show up
+// more often as the time goes and as the difficulty rises.
+func RandomEnemyKind() EnemyKind {
+	t := engine.elapsed
+	tough := difficulty.Toughness()
+	weights := [numberOfEnemyKinds]float64{
+		EnemySlime:       10,
+		EnemyBigSlime:    pixel.Clamp((t-30)/30, 0, 3) * tough,
+		EnemySpitter:     pixel.Clamp((t-45)/30, 0, 3) * tough,
+		EnemyBeetle:      pixel.Clamp((t-60)/30, 0, 2) * tough,
+		EnemyNecromancer: pixel.Clamp((t-90)/60, 0, 1) * tough,
+	}
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+	r := rand.Float64() * total
+	for k, w := range weights {
+		if r < w {
+			return EnemyKind(k)
+		}
+		r -= w
+	}
+	return EnemySlime
+}
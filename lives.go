@@ -0,0 +1,131 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
+	"golang.org/x/image/colornames"
+)
+
+// Lives counts the tries of the hero and remembers where it comes back.
+type Lives struct {
+	Left          int // including the current one
+	checkpoint    pixel.Vec
+	hasCheckpoint bool
+}
+
+func NewLives(n int) *Lives {
+	return &Lives{Left: n}
+}
+
+// Checkpoint makes the hero respawn at pos.
+func (l *Lives) Checkpoint(pos pixel.Vec) {
+	l.checkpoint = pos
+	l.hasCheckpoint = true
+}
+
+// Lose takes a life. It returns false when there are no lives left.
+func (l *Lives) Lose() bool {
+	if l.Left > 0 {
+		l.Left--
+	}
+	return l.Left > 0
+}
+
+// Respawn brings the dead hero back at the last checkpoint, or at the spot
+// furthest from enemies when there is none, and clears enemies around it.
+func (l *Lives) Respawn(h *Hero, horde *Horde) {
+	pos := l.checkpoint
+	if !l.hasCheckpoint {
+		pos = safeSpot(horde)
+	}
+	horde.Clear(pos, tuning.Lives.ClearRadius)
+	h.Respawn(pos, tuning.Lives.Immunity)
+}
+
+// safeSpot tries a few random spots and returns the one furthest from the
+// nearest enemy.
+func safeSpot(horde *Horde) pixel.Vec {
+	const tries, radius = 16, 200.0
+	best, bestDist := world.RandomVec(), -1.0
+	for i := 0; i < tries; i++ {
+		p := world.RandomVec()
+		dist := radius
+		if e := horde.Nearest(p, radius, nil); e != nil {
+			dist = e.Body().Pos.Sub(p).Len()
+		}
+		if dist > bestDist {
+			best, bestDist = p, dist
+		}
+	}
+	return best
+}
+
+// Draw marks the checkpoint with a small flag.
+func (l *Lives) Draw(imd *imdraw.IMDraw) {
+	if !l.hasCheckpoint {
+		return
+	}
+	p := l.checkpoint
+	imd.Color = colornames.Lightgray
+	imd.Push(p, p.Add(pixel.V(0, 10)))
+	imd.Line(1)
+	imd.Color = colornames.Gold
+	imd.Push(p.Add(pixel.V(0, 10)), p.Add(pixel.V(6, 8)), p.Add(pixel.V(0, 6)))
+	imd.Polygon(0)
+}
+
+// DrawLives draws an icon for every life left starting at pos.
+func DrawLives(t pixel.Target, pos pixel.Vec, icon *pixel.Sprite, col color.RGBA, n int) {
+	const scale = 2.0
+	w := icon.Frame().W() * scale
+	for i := 0; i < n; i++ {
+		center := pos.Add(pixel.V(float64(i)*(w+4)+w/2, w/2))
+		icon.DrawColorMask(t, pixel.IM.Scaled(pixel.ZV, scale).Moved(center), col)
+	}
+}
+
+// Clear removes living enemies within radius from pos, except the boss.
+// They vanish without a corpse or a score.
+func (h *Horde) Clear(pos pixel.Vec, radius float64) {
+	for _, e := range h.enemies {
+		if !e.Alive() || e.Kind() == EnemyBoss || e.Body().Pos.Sub(pos).Len() > radius {
+			continue
+		}
+		e.Kill()
+		e.Body().Deactivate()
+	}
+}
+
+// animateDeath spins and shrinks the dead hero.
+func (h *Hero) animateDeath() {
+	t := math.Min(1, (engine.elapsed-h.diedAt)/tuning.Lives.DeathTime)
+	h.Angle = t * 4 * math.Pi
+	s := 1 - 0.7*t
+	h.ScaleXY = pixel.V(s, s)
+	h.Visible = true
+}
+
+// DeathDone returns true when the dead hero has finished its animation.
+func (h *Hero) DeathDone() bool {
+	return !h.Alive() && engine.elapsed-h.diedAt >= tuning.Lives.DeathTime
+}
+
+// Respawn brings the hero back to life at pos with full health and stamina,
+// invulnerable for immunity seconds.
+func (h *Hero) Respawn(pos pixel.Vec, immunity float64) {
+	h.Pos = pos
+	h.velocity = pixel.ZV
+	h.health = h.maxHealth
+	h.Status.Clear()
+	h.Stamina.Value = h.Stamina.Max
+	h.Stamina.exhaustedUntil = 0
+	h.Angle = 0
+	h.ScaleXY = pixel.V(1, 1)
+	h.dashEnd = 0
+	h.trail = h.trail[:0]
+	h.immuneEnd = engine.elapsed + immunity
+	h.flashEnd = h.immuneEnd
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"image"
@@ -11,6 +12,7 @@ import (
 	"runtime"
 	"runtime/pprof"
 	"runtime/trace"
+	"strings"
 	"time"
 
 	"image/color"
@@ -67,9 +69,11 @@ func timeScheduler(init, rate float64) func(float64) float64 {
 }
 
 var (
-	engine *Engine
-	world  *World
-	hero   *Hero
+	engine      *Engine
+	world       *World
+	hero        *Hero
+	projectiles *Projectiles
+	difficulty  *Difficulty
 )
 
 var (
@@ -96,6 +100,30 @@ func run() {
 	}
 	fmt.Printf("%v tiles loaded\n", len(frames))
 
+	var set *WaveSet
+	mode := ModeEndless
+	if *wavesFile != "" {
+		set, err = LoadWaveSet(*wavesFile)
+		if err != nil {
+			panic(err)
+		}
+		mode = ModeWaves
+	}
+	var level json.RawMessage
+	if set != nil {
+		level = set.Tuning
+	}
+	tuning, err = LoadTuning(*tuningFile, mode, level)
+	if err != nil {
+		panic(err)
+	}
+	if flagsGiven()["drawtime"] {
+		tuning.Arrow.DrawTime = *drawTime
+	}
+	if flagsGiven()["breakchance"] {
+		tuning.Arrow.BreakChance = *breakChance
+	}
+
 	cfg := pixelgl.WindowConfig{
 		Title:  "A World",
 		Bounds: pixel.R(0, 0, 1400, 800),
@@ -111,6 +139,8 @@ func run() {
 	batchBg := pixel.NewBatch(tridBg, tileset)
 
 	imd := imdraw.New(nil)
+	hudImd := imdraw.New(nil)
+	hudBatch := pixel.NewBatch(&pixel.TrianglesData{}, tileset)
 
 	camera := NewCamera(engine.win)
 	camera.Pos = pixel.V(216, 83)
@@ -121,7 +151,12 @@ func run() {
 	atlas := text.NewAtlas(basicfont.Face7x13, text.ASCII)
 	debugText := text.New(pixel.V(8, engine.win.Bounds().Max.Y-16), atlas)
 	lostText := text.New(engine.win.Bounds().Center(), atlas)
+	victoryText := text.New(engine.win.Bounds().Center(), atlas)
+	choiceText := text.New(engine.win.Bounds().Center(), atlas)
 	scoreText := text.New(engine.win.Bounds().Max.Add(pixel.V(-236, -36)), atlas)
+	waveText := text.New(pixel.V(engine.win.Bounds().Center().X, engine.win.Bounds().Max.Y-64), atlas)
+	noteText := text.New(pixel.V(engine.win.Bounds().Center().X, 96), atlas)
+	comboText := text.New(engine.win.Bounds().Max.Add(pixel.V(-236, -72)), atlas)
 
 	sprWall := pixel.NewSprite(tileset, frames[256-37])
 	var sprBG []*pixel.Sprite
@@ -131,43 +166,139 @@ func run() {
 	sprBG = append(sprBG, pixel.NewSprite(tileset, frames[256-37]))
 	sprBG = append(sprBG, pixel.NewSprite(tileset, frames[256-36]))
 	world = NewWorld(40, 25, sSize, sprWall, sprBG, batchBg)
+	if in := world.Interior(); tuning.SpawnExclusion >= math.Min(in.W(), in.H())/2 {
+		panic(fmt.Errorf("spawnExclusion %v leaves no room to spawn in a %vx%v world", tuning.SpawnExclusion, in.W(), in.H()))
+	}
 
 	spr := pixel.NewSprite(tileset, frames[1])
+	class := findClass(*className)
+	if class != nil && !profile.Allows(class) {
+		log.Printf("class %q is not unlocked", class.ID)
+		class = nil
+	}
+	if class == nil {
+		engine.Pause()
+		if class = ChooseClass(atlas, spr, profile.Allows); class == nil {
+			return
+		}
+		engine.Resume()
+	}
 	hero = NewHero(
 		spr,
 		pixel.V(48, 100),
-		90,
-		400,
+		tuning.Hero.MaxVel,
+		tuning.Hero.Accel,
 	)
+	hero.maxHealth = tuning.Hero.Health
+	hero.health = hero.maxHealth
+	hero.Stamina = NewStamina(tuning.Stamina)
+	hero.HitImmuneTime = tuning.Hero.Invulnerability
+	hero.DashSpeed = tuning.Hero.Dash.Speed
+	hero.DashTime = tuning.Hero.Dash.Time
+	hero.DashCooldown = tuning.Hero.Dash.Cooldown
+	hero.ImmuneTime = tuning.Hero.Dash.Immunity
+	lives := NewLives(*livesCount)
 	r := pixel.R(-spr.Frame().W()/2.5, -spr.Frame().H()/2.5, spr.Frame().W()/2.5, spr.Frame().H()/3)
 	hero.Collider = &r
 
 	sprBow := pixel.NewSprite(tileset, frames[28])
 	bow := NewEntity(sprBow, pixel.ZV)
 	bow.Color = colornames.Gold
+	bash := NewBowBash()
 
 	sprArrow := pixel.NewSprite(tileset, frames[26])
 	sprStuckArrow := pixel.NewSprite(tileset, frames[27])
-	arrows := make([]*Arrow, 3)
-	for i := range arrows {
-		arrows[i] = NewArrow(sprArrow, sprStuckArrow)
+	quiver := NewQuiver(sprArrow, sprStuckArrow, 0, tuning.Arrow.DrawTime, tuning.Arrow.BreakChance)
+	quiver.ActiveReload = *activeReload
+	quiver.ChargeTime = tuning.Arrow.ChargeTime
+	quiver.ChargeDamage = tuning.Arrow.ChargeDamage
+	held := 0.0 // seconds the shot is held
+	class.Equip(hero, quiver)
+	arrows := class.Arrows
+	if flagsGiven()["arrows"] {
+		arrows = make([]ArrowType, *quiverSize)
 	}
-	for i := range arrows {
-		arrows[i].ToQuiver()
+	for _, t := range arrows {
+		quiver.Upgrade(1, t)
 	}
-	arrowsQ := make([]*Arrow, 0, 3)
-	arrowsQ = append(arrowsQ, arrows...)
-	var arrowInHand *Arrow
-	timeToDrawArrow := 1.0
-	drawArrowDone := engine.elapsed + timeToDrawArrow
-
-	sprSlime := pixel.NewSprite(tileset, frames[15])
-	slimes := make([]*Slime, 200)
-	for i := range slimes {
-		slimes[i] = NewSlime(sprSlime)
+	if profile != nil {
+		profile.Equip(quiver)
+	}
+	nextQuiverUpgrade := tuning.Quiver.UpgradeScore
+	combo := NewCombo(tuning.Combo.Window, tuning.Combo.MaxChain)
+	showTrajectory := *trajectoryOverlay
+	var trajectory []TrajectoryPoint
+
+	horde := NewHorde(200, EnemySprites{
+		Slime:       pixel.NewSprite(tileset, frames[15]),
+		Spitter:     pixel.NewSprite(tileset, frames[173]),
+		Beetle:      pixel.NewSprite(tileset, frames[232]),
+		Necromancer: pixel.NewSprite(tileset, frames[234]),
+		Crown:       pixel.NewSprite(tileset, frames[229]),
+	})
+	horde.Corpses = CorpseRules{
+		Lifetime: *corpseLifetime,
+		Fade:     3,
+		Max:      *corpseMax,
+		Slow:     *corpseSlow,
+		Harvest:  *corpseHarvest,
+	}
+	var pickups *Pickups
+	if *pickupsOn {
+		in := world.Interior()
+		rules := tuning.Pickups
+		rules.Points = []pixel.Vec{
+			in.Min.Add(pixel.V(24, 24)),
+			in.Max.Sub(pixel.V(24, 24)),
+		}
+		pickups = NewPickups([numberOfPickupKinds]*pixel.Sprite{
+			PickupArrows:       pixel.NewSprite(tileset, frames[24]),
+			PickupHaste:        pixel.NewSprite(tileset, frames[175]),
+			PickupDoubleStrike: pixel.NewSprite(tileset, frames[4]),
+			PickupQuickHands:   pixel.NewSprite(tileset, frames[16]),
+		}, rules)
+	}
+	statusIcons := make([]*pixel.Sprite, numberOfStatusKinds)
+	statusIcons[StatusSlow] = pixel.NewSprite(tileset, frames[25])
+	statusIcons[StatusBurn] = pixel.NewSprite(tileset, frames[30])
+	statusIcons[StatusStun] = pixel.NewSprite(tileset, frames[42])
+	statusIcons[StatusPoison] = pixel.NewSprite(tileset, frames[5])
+	buffIcons := make([]*pixel.Sprite, numberOfBuffKinds)
+	buffIcons[BuffHaste] = pixel.NewSprite(tileset, frames[175])
+	buffIcons[BuffDoubleStrike] = pixel.NewSprite(tileset, frames[4])
+	buffIcons[BuffQuickHands] = pixel.NewSprite(tileset, frames[16])
+	buffIcons[BuffVolley] = pixel.NewSprite(tileset, frames[26])
+	abilityIcons := [numberOfAbilities]*pixel.Sprite{
+		AbilityVolley:  pixel.NewSprite(tileset, frames[26]),
+		AbilityStomp:   pixel.NewSprite(tileset, frames[42]),
+		AbilityBlink:   pixel.NewSprite(tileset, frames[175]),
+		AbilityRush:    pixel.NewSprite(tileset, frames[16]),
+		AbilityBulwark: pixel.NewSprite(tileset, frames[256-37]),
 	}
+	projectiles = NewProjectiles(pixel.NewSprite(tileset, frames[7]), 256)
 	nextSlime := timeScheduler(8.0, 0.01)
-	slimeRecycle := 0
+	var waves *WaveDirector
+	bounds := defaultDifficulty
+	if set != nil {
+		if set.Difficulty != nil {
+			bounds = *set.Difficulty
+		}
+		waves = NewWaveDirector(set, horde)
+	}
+	difficulty = NewDifficulty(bounds, *adaptive)
+	var upgrades *UpgradeChoice
+	upgradeScore := 0
+	upgradesDue := 0
+	if *upgradesFile != "" {
+		pool, err := LoadUpgradePool(*upgradesFile)
+		if err != nil {
+			panic(err)
+		}
+		upgrades = NewUpgradeChoice(pool)
+		upgradeScore = pool.Score
+	}
+	nextUpgradeScore := upgradeScore
+	showDifficulty := *difficultyOverlay
 
 	targetFrameTime := 16500 * time.Microsecond
 	gcOnFrame := 160
@@ -186,6 +317,28 @@ func run() {
 
 	gameOver := false
 	gameScore := 0
+	victory := false
+	bossesSlain := 0
+	nextBossTime := tuning.Boss.Interval
+	kills, shots := 0, 0
+	recorded := false
+	recordRun := func() {
+		if profile == nil || recorded {
+			return
+		}
+		recorded = true
+		profile.Record(RunStats{
+			Score:     gameScore,
+			Kills:     kills,
+			Shots:     shots,
+			Bosses:    bossesSlain,
+			BestCombo: combo.Best(),
+			Time:      engine.elapsed,
+		})
+		if err := profile.Save(); err != nil {
+			log.Printf("saving profile: %v", err)
+		}
+	}
 
 	win := engine.win
 	// prewarm input
@@ -222,6 +375,7 @@ func run() {
 			}
 		}
 		if win.Pressed(pixelgl.KeyEscape) {
+			recordRun()
 			return
 		}
 
@@ -229,105 +383,244 @@ func run() {
 		camMat := camera.GetMatrix()
 		mousePos := camMat.Unproject(win.MousePosition())
 
-		hero.Update()
-
-		// bow
-		if hero.Alive() {
-			lookVec := mousePos.Sub(hero.Pos)
-			dir := lookVec.Unit()
-			bow.Pos = hero.Pos.Add(dir.Scaled(ArrowStartDistance - 3))
-			bow.Angle = dir.Angle()
-
-			lookDistance := pixel.Clamp(lookVec.Len(), 0, 64)
-			lookAt := hero.Pos.Add(
-				lookVec.Unit().Scaled(lookDistance))
-			lookAt = lookAt.Add(hero.velocity.Scaled(0.64))
-			camera.Follow(lookAt)
-		}
-
-		// arrows
-		for _, a := range arrows {
-			if a.Active {
-				a.Update()
+		if victory && win.JustPressed(pixelgl.KeyEnter) {
+			victory = false
+			engine.Resume()
+		}
+		if upgrades != nil && upgradesDue > 0 && !upgrades.Active && !victory && hero.Alive() {
+			upgradesDue--
+			if upgrades.Roll(quiver) {
+				engine.Pause()
+				choiceText.Clear()
+				fmt.Fprint(choiceText, upgrades.Describe())
 			}
 		}
-
-		if arrowInHand == nil && engine.elapsed > drawArrowDone {
-			// Move arrow from the quiver to hands.
-			if len(arrowsQ) > 0 {
-				last := len(arrowsQ) - 1
-				arrowInHand = arrowsQ[last]
-				arrowsQ = arrowsQ[:last]
-				arrowInHand.ToHands()
+		if upgrades != nil && upgrades.Active {
+			for i, key := range []pixelgl.Button{pixelgl.Key1, pixelgl.Key2, pixelgl.Key3} {
+				if win.JustPressed(key) {
+					if u := upgrades.Choose(i); u != nil {
+						u.Apply(quiver)
+						engine.Resume()
+					}
+					break
+				}
 			}
 		}
 
-		if hero.Alive() {
-			shoot := (win.JustPressed(pixelgl.MouseButton1) || win.JustPressed(pixelgl.KeySpace))
-			if shoot && arrowInHand != nil {
-				arrowInHand.Fly(hero.Pos, mousePos, hero.velocity.Scaled(0.22))
-				arrowInHand = nil
-				if len(arrowsQ) > 0 {
-					drawArrowDone = engine.elapsed + timeToDrawArrow
+		if !engine.Paused() {
+			hero.Update()
+
+			// bow
+			if hero.Alive() {
+				lookVec := mousePos.Sub(hero.Pos)
+				dir := lookVec.Unit()
+				bow.Pos = hero.Pos.Add(dir.Scaled(ArrowStartDistance - 3))
+				bow.Angle = dir.Angle()
+				if win.JustPressed(pixelgl.MouseButton2) || win.JustPressed(pixelgl.KeyE) {
+					bash.Start(hero.Pos, dir, horde)
 				}
+				if win.JustPressed(pixelgl.KeyQ) {
+					// Dash where the hero runs, or where it looks when standing still.
+					dashDir := hero.velocity
+					if dashDir == pixel.ZV {
+						dashDir = dir
+					}
+					if hero.Dash(dashDir) && *dashCancel {
+						quiver.CancelDraw()
+					}
+				}
+				if win.JustPressed(pixelgl.KeyC) {
+					hero.UseAbility(mousePos, horde)
+				}
+				bash.Animate(bow, hero.Pos)
+
+				lookDistance := pixel.Clamp(lookVec.Len(), 0, 64)
+				lookAt := hero.Pos.Add(
+					lookVec.Unit().Scaled(lookDistance))
+				lookAt = lookAt.Add(hero.velocity.Scaled(0.64))
+				camera.Follow(lookAt)
 			}
 
-			heroColBig := hero.AbsCollider()
-			quiverIdx := 0
-			for i := range arrows {
-				a := arrows[i]
-				if a.State == ArrowStuck {
-					if collides(a.AbsCollider(), heroColBig) {
-						if len(arrowsQ) == 0 {
-							drawArrowDone = engine.elapsed + timeToDrawArrow
+			// arrows
+			quiver.Update()
+
+			if win.JustPressed(pixelgl.KeyF3) {
+				showDifficulty = !showDifficulty
+			}
+			if win.JustPressed(pixelgl.KeyT) {
+				showTrajectory = !showTrajectory
+			}
+			aimTarget := mousePos
+			if *aimAssist {
+				aimTarget = AimAssist(hero.Pos, mousePos, horde, aimAssistRadius, aimAssistCone, aimAssistStrength)
+			}
+			trajectory = trajectory[:0]
+
+			if hero.Alive() {
+				// The arrow leaves on release, holding the shot charges it.
+				holding := win.Pressed(pixelgl.MouseButton1) || win.Pressed(pixelgl.KeySpace)
+				shoot := win.JustReleased(pixelgl.MouseButton1) || win.JustReleased(pixelgl.KeySpace)
+				if holding && quiver.InHand() != nil {
+					held += engine.dt
+					if held > tuning.Arrow.ChargeDelay {
+						if hero.Stamina.Drain(hero.Stamina.Charge) {
+							quiver.Charge()
+						} else {
+							quiver.Uncharge()
 						}
-						arrowsQ = append(arrowsQ, a)
-						a.ToQuiver()
 					}
+				} else {
+					held = 0
+				}
+				if win.JustPressed(pixelgl.KeyR) {
+					quiver.Reload()
+				}
+				if shoot && quiver.Shoot(hero.Pos, aimTarget, hero.velocity.Scaled(HeroVelocityTransfer)) {
+					difficulty.Shot()
+					shots++
 				}
+				quiver.Collect(hero.AbsCollider())
+				quiver.Attach(hero.Pos, aimTarget)
+				if a := quiver.InHand(); showTrajectory && a != nil {
+					trajectory = PredictFlight(a, hero.Pos, aimTarget, hero.velocity.Scaled(HeroVelocityTransfer), trajectory)
+				}
+			}
 
-				if a.State == ArrowHands {
-					a.AttachToHands(hero.Pos, mousePos)
+			// enemies
+			horde.Update(quiver.Arrows(), func(e Enemy, killer *Arrow) {
+				if e.Kind() == EnemyBoss {
+					gameScore += bossScore
+					victory = true
+					bossesSlain++
+					if hero.Alive() {
+						lives.Checkpoint(hero.Pos)
+					}
+					victoryText.Clear()
+					fmt.Fprintf(victoryText, "Victory!\nThe Slime King is slain\nPress Enter to continue")
+					engine.Pause()
+				}
+				difficulty.Kill()
+				kills++
+				if pickups != nil {
+					pickups.Drop(e.Body().Pos)
 				}
-				if a.State == ArrowQuiver {
-					a.AttachToQuiver(hero.Pos, quiverIdx)
-					quiverIdx++
+				if killer == nil {
+					return
+				}
+				score := e.Body().Pos.Sub(hero.Pos).Len() * (1 + engine.elapsed/1000)
+				gameScore += int(math.Round(score)) * combo.Kill(killer)
+			})
+
+			horde.UpdateCorpses(func() bool {
+				return quiver.Add(ArrowWooden)
+			})
+			projectiles.Update()
+			if pickups != nil {
+				pickups.Update(func(kind PickupKind) {
+					switch kind {
+					case PickupArrows:
+						n := 0
+						// Only free slots are filled, the capacity grows by
+						// score and upgrades alone.
+						for i := 0; i < pickupArrows && quiver.Add(ArrowSteel); i++ {
+							n++
+						}
+						if n == 0 {
+							pickups.Notify("Quiver is full")
+						} else {
+							pickups.Notify("+%d arrows", n)
+						}
+					case PickupHaste:
+						hero.Buffs.Apply(BuffHaste)
+						pickups.Notify("Haste!")
+					case PickupDoubleStrike:
+						hero.Buffs.Apply(BuffDoubleStrike)
+						pickups.Notify("Double strike!")
+					case PickupQuickHands:
+						hero.Buffs.Apply(BuffQuickHands)
+						pickups.Notify("Quick hands!")
+					}
+				})
+			}
+
+			if waves != nil {
+				waves.Update()
+				if waves.Cleared() {
+					upgradesDue++
+					if hero.Alive() {
+						lives.Checkpoint(hero.Pos)
+					}
+				}
+			} else {
+				if engine.elapsed > nextSlimeTime {
+					horde.Spawn(RandomEnemyKind())
+					nextSlimeTime = engine.elapsed + (nextSlime(engine.elapsed)-engine.elapsed)/difficulty.SpawnRate()
+				}
+				if engine.elapsed > nextBossTime {
+					if horde.Boss() == nil {
+						horde.Spawn(EnemyBoss)
+					}
+					nextBossTime += tuning.Boss.Interval
 				}
 			}
-		}
 
-		// slimes
-		for i := range slimes {
-			if slimes[i].Active {
-				s := slimes[i]
-				aliveBefore := s.Alive
-				s.Update(arrows)
-				if aliveBefore && !s.Alive {
-					gameScore += int(math.Round(s.Pos.Sub(hero.Pos).Len() * (1 + engine.elapsed/1000)))
+			if hero.Alive() {
+				grounded := 0
+				for _, a := range quiver.Arrows() {
+					if a.State == ArrowStuck {
+						grounded++
+					}
 				}
+				difficulty.Update(hero.health, hero.maxHealth, grounded, quiver.Owned())
 			}
-		}
 
-		if engine.elapsed > nextSlimeTime {
-			free := firstFreeSlime(slimes)
-			if free == -1 {
-				free = slimeRecycle
-				slimeRecycle++
-				if slimeRecycle >= len(slimes) {
-					slimeRecycle = 0
+			if upgrades != nil {
+				if waves == nil && gameScore >= nextUpgradeScore {
+					upgradesDue++
+					nextUpgradeScore += upgradeScore
 				}
+			} else if gameScore >= nextQuiverUpgrade && quiver.Capacity < tuning.Quiver.MaxCapacity {
+				quiver.Upgrade(1, ArrowSteel)
+				nextQuiverUpgrade += tuning.Quiver.UpgradeScore
 			}
-			slimes[free].Spawn()
-			nextSlimeTime = nextSlime(engine.elapsed)
 		}
 
-		if !gameOver && !hero.Alive() {
+		if !gameOver && hero.DeathDone() && lives.Lose() {
+			lives.Respawn(hero, horde)
+			projectiles.Clear()
+		}
+		if !gameOver && hero.DeathDone() {
 			gameOver = true
+			recordRun()
 			lostText.Clear()
-			fmt.Fprint(lostText, "Game Over!\nPress Esc to exit")
+			fmt.Fprintf(lostText, "Game Over!\nBest combo: x%d\nKings slain: %d\n", combo.Best(), bossesSlain)
+			if upgrades != nil {
+				fmt.Fprintf(lostText, "Upgrades: %s\n", upgrades.Summary())
+			}
+			if profile != nil {
+				fmt.Fprintf(lostText, "Bank: %d\n", profile.Bank)
+			}
+			fmt.Fprintf(lostText, "Press Esc to exit")
 		}
 		scoreText.Clear()
 		fmt.Fprintf(scoreText, "Game score: %d", gameScore)
+		waveText.Clear()
+		if waves != nil {
+			for _, line := range strings.Split(waves.Announcement(), "\n") {
+				waveText.Dot.X -= waveText.BoundsOf(line).W() / 2
+				fmt.Fprintln(waveText, line)
+			}
+		}
+		noteText.Clear()
+		if pickups != nil {
+			note := pickups.Notification()
+			noteText.Dot.X -= noteText.BoundsOf(note).W() / 2
+			fmt.Fprint(noteText, note)
+		}
+		comboText.Clear()
+		if m := combo.Multiplier(); m > 1 {
+			fmt.Fprintf(comboText, "Combo x%d", m)
+		}
 
 		// debug text
 		debugText.Clear()
@@ -340,6 +633,10 @@ func run() {
 		fmt.Fprintf(debugText, "max dt: %6.5f\n", dtMax)
 		fmt.Fprintf(debugText, "dt upd: %6.5f\n", dtUpdateMax)
 		fmt.Fprintf(debugText, "dt dra: %6.5f\n", dtDrawMax)
+		if showDifficulty {
+			fmt.Fprintln(debugText)
+			difficulty.Report(debugText)
+		}
 
 		dtUpdate = time.Since(dtUpdateSt).Seconds()
 		if dtUpdate > dtUpdateMax {
@@ -356,43 +653,73 @@ func run() {
 		// tileset batch
 		batch.Clear()
 		world.Draw(batch)
-		for i := range slimes {
-			if !slimes[i].Alive {
-				slimes[i].Draw(batch)
-			}
-		}
-		for _, a := range arrows {
+		horde.DrawCorpses(batch)
+		for _, a := range quiver.Arrows() {
 			if a.State == ArrowStuck {
 				a.Draw(batch)
 			}
 		}
-		for i := range slimes {
-			if slimes[i].Alive {
-				slimes[i].Draw(batch)
-			}
+		horde.DrawAlive(batch)
+		projectiles.Draw(batch)
+		if pickups != nil {
+			pickups.Draw(batch)
 		}
 		bow.Draw(batch)
-		for _, a := range arrows {
+		for _, a := range quiver.Arrows() {
 			if a.State != ArrowStuck {
 				a.Draw(batch)
 			}
 		}
+		hero.DrawTrail(batch)
 		hero.Draw(batch)
 		batch.Draw(win)
 
 		imd.Clear()
 		imd.Color = colornames.Blueviolet
 		//drawRect(imd, hero.Collider.Moved(origin))
+		DrawTrajectory(imd, trajectory)
+		lives.Draw(imd)
+		if hero.Alive() {
+			quiver.DrawReload(imd, hero.Pos)
+			hero.DrawDashCooldown(imd)
+			hero.DrawAbility(imd)
+			hero.DrawStamina(imd)
+		}
 		imd.Draw(win)
 
 		// debug text
 		win.SetMatrix(pixel.IM)
 		debugText.Draw(win, pixel.IM.Scaled(debugText.Orig, 1))
-		if !hero.Alive() {
+		if gameOver {
 			lostText.DrawColorMask(win, pixel.IM.Scaled(lostText.Bounds().Center(), 6), colornames.Black)
 			lostText.DrawColorMask(win, pixel.IM.Scaled(lostText.Bounds().Center(), 6).Moved(pixel.V(-4, 6)), colornames.White)
 		}
 		scoreText.Draw(win, pixel.IM.Scaled(scoreText.Orig, 2))
+		hudImd.Clear()
+		if b := horde.Boss(); b != nil {
+			drawBossHealth(hudImd, win.Bounds(), b)
+		}
+		hudBatch.Clear()
+		DrawLives(hudBatch, win.Bounds().Max.Add(pixel.V(-236, -112)), spr, mix(colornames.White, class.Tint, 0.5), lives.Left)
+		if hero.Alive() {
+			DrawStatusIcons(hudBatch, hudImd, pixel.V(16, 24), &hero.Status, statusIcons)
+			DrawBuffIcons(hudBatch, hudImd, pixel.V(16, 64), &hero.Buffs, buffIcons)
+			drawTimerIcon(hudBatch, hudImd, pixel.V(win.Bounds().Max.X-72, 24), abilityIcons[class.Ability], class.Tint, hero.AbilityReady(), 0)
+		}
+		hudImd.Draw(win)
+		hudBatch.Draw(win)
+		if upgrades != nil && upgrades.Active {
+			choiceText.DrawColorMask(win, pixel.IM.Scaled(choiceText.Bounds().Center(), 3), colornames.Black)
+			choiceText.DrawColorMask(win, pixel.IM.Scaled(choiceText.Bounds().Center(), 3).Moved(pixel.V(-2, 3)), colornames.Lightgoldenrodyellow)
+		}
+		if victory {
+			victoryText.DrawColorMask(win, pixel.IM.Scaled(victoryText.Bounds().Center(), 5), colornames.Black)
+			victoryText.DrawColorMask(win, pixel.IM.Scaled(victoryText.Bounds().Center(), 5).Moved(pixel.V(-4, 6)), colornames.Gold)
+		}
+		waveText.Draw(win, pixel.IM.Scaled(waveText.Orig, 3))
+		noteText.Draw(win, pixel.IM.Scaled(noteText.Orig, 3))
+		comboColor := pixel.ToRGBA(colornames.Orange).Scaled(0.3 + 0.7*combo.Remaining())
+		comboText.DrawColorMask(win, pixel.IM.Scaled(comboText.Orig, 2), comboColor)
 
 		engine.fpsHandler()
 		win.Update()
@@ -406,6 +733,108 @@ func run() {
 
 var vsync = flag.Bool("vsync", false, "use vsync")
 
+var (
+	quiverSize  = flag.Int("arrows", 3, "number of wooden arrows in the quiver at start instead of the arrows of the class")
+	className   = flag.String("class", "", "play as `ranger`, heavy, trickster or an unlocked class without the title screen")
+	drawTime    = flag.Float64("drawtime", 1.0, "seconds to draw an arrow from the quiver, overrides the tuning")
+	breakChance = flag.Float64("breakchance", 0.1, "chance for an arrow to break on impact, overrides the tuning")
+
+	tuningFile   = flag.String("tuning", "tuning.json", "balance numbers with overrides per mode, empty for the built-in ones")
+	wavesFile    = flag.String("waves", "waves.json", "wave definition file, empty for endless spawning")
+	upgradesFile = flag.String("upgrades", "upgrades.json", "upgrades offered between waves, empty to only grow the quiver with score")
+
+	activeReload = flag.Bool("activereload", false, "press R in the sweet spot to draw arrows faster")
+	dashCancel   = flag.Bool("dashcancel", false, "dashing interrupts drawing an arrow")
+	pickupsOn    = flag.Bool("pickups", true, "scatter pickups around the world")
+	livesCount   = flag.Int("lives", 3, "tries of the hero before the game is over")
+)
+
+var (
+	corpseLifetime = flag.Float64("corpselife", 30, "seconds before corpses are gone, 0 to keep them")
+	corpseMax      = flag.Int("corpsemax", 60, "most corpses lying around, 0 for no limit")
+	corpseSlow     = flag.Float64("corpseslow", 1, "part of the speed kept after a second of walking over corpses")
+	corpseHarvest  = flag.Float64("harvest", 0, "chance for a corpse to hold an arrow")
+)
+
+var (
+	trajectoryOverlay = flag.Bool("trajectory", false, "show the predicted flight of the arrow (toggle with T)")
+	aimAssist         = flag.Bool("aimassist", false, "gently nudge the aim towards the nearest slime")
+	difficultyOverlay = flag.Bool("difficulty", false, "show the decisions of the difficulty director (toggle with F3)")
+)
+
+var (
+	profileFile = flag.String("profile", "", "profile `file`, empty for the user config directory")
+	noProfile   = flag.Bool("noprofile", false, "neither load nor save the profile")
+	showProfile = flag.Bool("showprofile", false, "print the stats and unlocks of the profile and exit")
+	buyUnlock   = flag.String("buy", "", "buy the unlock `id` with the score in the bank and exit")
+)
+
+// profile persists between runs, nil when disabled.
+var profile *Profile
+
+// openProfile loads the profile. Errors disable the profile rather than the
+// game, so a broken file is never overwritten.
+func openProfile() *Profile {
+	path := *profileFile
+	if path == "" {
+		var err error
+		if path, err = DefaultProfilePath(); err != nil {
+			log.Printf("profile disabled: %v", err)
+			return nil
+		}
+	}
+	p, err := LoadProfile(path)
+	if err != nil {
+		log.Printf("profile disabled: %v", err)
+		return nil
+	}
+	return p
+}
+
+// flagsGiven returns the names of the flags set on the command line.
+func flagsGiven() map[string]bool {
+	given := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	return given
+}
+
+var adaptive = flag.Bool("adaptive", true, "adapt the difficulty to the performance of the player")
+
+const (
+	// Aim assist picks enemies within this angle from the aiming direction
+	// and not further than aimAssistRadius from the target.
+	aimAssistCone     = math.Pi / 12
+	aimAssistRadius   = 64
+	aimAssistStrength = 0.5
+)
+
+const bossScore = 5000
+
+// drawBossHealth draws the health bar of the boss at the top of the screen.
+func drawBossHealth(imd *imdraw.IMDraw, screen pixel.Rect, b *Boss) {
+	const w, h = 480.0, 14.0
+	min := pixel.V(screen.Center().X-w/2, screen.Max.Y-36)
+	imd.Color = colornames.Black
+	imd.Push(min.Sub(pixel.V(2, 2)), min.Add(pixel.V(w+2, h+2)))
+	imd.Rectangle(0)
+	imd.Color = colornames.Crimson
+	imd.Push(min, min.Add(pixel.V(w*b.Health/b.MaxHealth, h)))
+	imd.Rectangle(0)
+	imd.Color = colornames.Gold
+	for _, t := range bossPhaseThresholds[1:] {
+		x := min.X + w*t
+		imd.Push(pixel.V(x, min.Y), pixel.V(x, min.Y+h))
+		imd.Line(2)
+	}
+}
+
+const (
+	// Arrows pickup fills up to this many free slots of the quiver.
+	pickupArrows = 2
+)
+
 var (
 	cpuprofile   = flag.String("cpuprofile", "", "write cpu profile to file")
 	memprofile   = flag.String("memprofile", "", "write memory profile to `file`")
@@ -417,6 +846,27 @@ func main() {
 		runtime.MemProfileRate = 16
 	}
 	flag.Parse()
+	if !*noProfile {
+		profile = openProfile()
+	}
+	if *buyUnlock != "" || *showProfile {
+		if profile == nil {
+			log.Fatal("no profile")
+		}
+		if *buyUnlock != "" {
+			if err := profile.Buy(*buyUnlock); err != nil {
+				log.Fatal(err)
+			}
+			if err := profile.Save(); err != nil {
+				log.Fatal(err)
+			}
+		}
+		profile.Report(os.Stdout)
+		return
+	}
+	if profile != nil {
+		profile.SyncSettings()
+	}
 	if *cpuprofile != "" {
 		f, err := os.Create(*cpuprofile)
 		if err != nil {
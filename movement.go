@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/faiface/pixel"
+)
+
+// MovementKind is the way an enemy approaches the hero.
+type MovementKind uint8
+
+const (
+	// MoveLunge chases the hero and rushes in a fixed direction when close.
+	MoveLunge MovementKind = iota
+	// MoveChase goes straight at the hero.
+	MoveChase
+	// MoveSpiral circles in towards the hero, Turn is the angle off the
+	// straight line in radians.
+	MoveSpiral
+	// MoveZigzag swings Turn radians to both sides every Period seconds.
+	MoveZigzag
+	// MoveOrbit circles the hero at Range for Period seconds and then dashes
+	// at it with speed multiplied by Dash.
+	MoveOrbit
+	// MoveAmbush waits until the hero comes within Range and then chases it
+	// with speed multiplied by Dash.
+	MoveAmbush
+)
+
+var movementKindNames = [...]string{
+	MoveLunge:  "lunge",
+	MoveChase:  "chase",
+	MoveSpiral: "spiral",
+	MoveZigzag: "zigzag",
+	MoveOrbit:  "orbit",
+	MoveAmbush: "ambush",
+}
+
+func (k MovementKind) String() string {
+	if int(k) < len(movementKindNames) {
+		return movementKindNames[k]
+	}
+	return fmt.Sprintf("MovementKind(%d)", k)
+}
+
+func (k *MovementKind) UnmarshalText(text []byte) error {
+	for i, name := range movementKindNames {
+		if name == string(text) {
+			*k = MovementKind(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown movement %q", text)
+}
+
+// Movement is a movement pattern with its parameters. Parameters which do not
+// matter for the kind are ignored.
+type Movement struct {
+	Kind   MovementKind `json:"kind"`
+	Turn   float64      `json:"turn"`
+	Period float64      `json:"period"`
+	Range  float64      `json:"range"`
+	Dash   float64      `json:"dash"`
+}
+
+// Default movement of enemies which follow movement patterns.
+var enemyMovement = [numberOfEnemyKinds]Movement{
+	EnemySlime:     {Kind: MoveLunge},
+	EnemySlimeling: {Kind: MoveZigzag, Turn: 0.7, Period: 0.6},
+	EnemyBigSlime:  {Kind: MoveSpiral, Turn: 0.9},
+}
+
+// Distances of the lunge: slimes speed up within lungeRange and pick the
+// fixed direction within lungeFixRange.
+const (
+	lungeRange    = 92
+	lungeFixRange = 48
+	orbitDashTime = 0.8
+)
+
+func (m *Movement) validate() error {
+	if m.Kind >= MovementKind(len(movementKindNames)) {
+		return fmt.Errorf("unknown movement %d", m.Kind)
+	}
+	if m.Turn < 0 || m.Period < 0 || m.Range < 0 || m.Dash < 0 {
+		return fmt.Errorf("movement parameters must not be negative")
+	}
+	if (m.Kind == MoveOrbit || m.Kind == MoveAmbush) && m.Range == 0 {
+		return fmt.Errorf("%v movement needs a range", m.Kind)
+	}
+	if m.Turn >= math.Pi/2 {
+		return fmt.Errorf("movement turn must be less than %.2f", math.Pi/2)
+	}
+	return nil
+}
+
+// mover keeps the state of a movement pattern for one enemy.
+type mover struct {
+	Movement
+	side     float64 // 1 or -1, which way to turn
+	started  float64
+	dashing  bool
+	dashEnd  float64
+	fixed    bool
+	fixedDir pixel.Vec
+}
+
+func (m *mover) reset(mv Movement) {
+	m.Movement = mv
+	m.side = 1
+	if rand.Intn(2) == 0 {
+		m.side = -1
+	}
+	m.started = engine.elapsed
+	m.dashing = false
+	m.fixed = false
+}
+
+// direction returns where the enemy at pos wants to go and the factor for
+// its speed.
+func (m *mover) direction(pos pixel.Vec) (pixel.Vec, float64) {
+	toHero := hero.Pos.Sub(pos)
+	dist := toHero.Len()
+	dir := toHero.Unit()
+
+	switch m.Kind {
+	case MoveChase:
+		return dir, 1
+
+	case MoveSpiral:
+		// Keeping the same angle to the hero draws a logarithmic spiral.
+		return dir.Rotated(m.side * m.Turn), 1
+
+	case MoveZigzag:
+		period := math.Max(m.Period, 0.1)
+		swing := m.side
+		if math.Mod(engine.elapsed-m.started, 2*period) > period {
+			swing = -swing
+		}
+		return dir.Rotated(swing * m.Turn), 1
+
+	case MoveOrbit:
+		if m.dashing {
+			if engine.elapsed > m.dashEnd {
+				m.dashing = false
+				m.started = engine.elapsed
+			}
+			return m.fixedDir, math.Max(m.Dash, 1)
+		}
+		if engine.elapsed-m.started > m.Period && dist < m.Range*1.5 {
+			m.dashing = true
+			m.dashEnd = engine.elapsed + orbitDashTime
+			m.fixedDir = dir
+			return dir, math.Max(m.Dash, 1)
+		}
+		if dist > m.Range {
+			return dir, 1
+		}
+		return dir.Normal().Scaled(m.side), 1
+
+	case MoveAmbush:
+		if !m.dashing {
+			if dist > m.Range {
+				return dir, 0
+			}
+			m.dashing = true
+		}
+		return dir, math.Max(m.Dash, 1)
+
+	default:
+		return m.lunge(dir, dist)
+	}
+}
+
+// lunge is the original slime movement: it slows down in front of the hero,
+// then sticks to one direction and rushes through.
+func (m *mover) lunge(dir pixel.Vec, dist float64) (pixel.Vec, float64) {
+	if dist > lungeRange {
+		m.fixed = false
+		return dir, 1
+	}
+	if m.fixed {
+		dir = m.fixedDir.Add(dir.Scaled(0.5)).Unit()
+	} else if dist < lungeFixRange {
+		m.fixedDir = dir
+		m.fixed = true
+	}
+	// Speed up at lungeRange and slow down when closer than 42.
+	return dir, 1 - (42-dist)/lungeRange
+}
+
+// blocked is called when a wall stops the enemy.
+func (m *mover) blocked() {
+	m.fixed = false
+	if m.dashing && m.Kind == MoveOrbit {
+		m.dashing = false
+		m.started = engine.elapsed
+	}
+	m.side = -m.side
+}
+
+// Mover is an enemy whose movement pattern may be changed, for example by a wave.
+type Mover interface {
+	SetMovement(m Movement)
+}
+
+// patterned is the mover of enemies which move their own way unless a
+// movement pattern is set. Spawn should turn it off.
+type patterned struct {
+	mover
+	on bool
+}
+
+// SetMovement makes the enemy follow the pattern until the next spawn.
+func (p *patterned) SetMovement(m Movement) {
+	p.reset(m)
+	p.on = true
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"math/rand"
+
+	"github.com/faiface/pixel"
+	"golang.org/x/image/colornames"
+)
+
+type PickupKind uint8
+
+const (
+	// PickupArrows gives the hero more arrows.
+	PickupArrows PickupKind = iota
+	// PickupHaste makes the hero run faster for a while.
+	PickupHaste
+	// PickupDoubleStrike doubles the damage of arrows for a while.
+	PickupDoubleStrike
+	// PickupQuickHands speeds up drawing and shooting for a while.
+	PickupQuickHands
+	numberOfPickupKinds
+)
+
+var pickupNames = [...]string{
+	PickupArrows:       "Arrows",
+	PickupHaste:        "Haste",
+	PickupDoubleStrike: "Double strike",
+	PickupQuickHands:   "Quick hands",
+}
+
+var pickupColors = [...]color.RGBA{
+	PickupArrows:       colornames.Goldenrod,
+	PickupHaste:        colornames.Cyan,
+	PickupDoubleStrike: colornames.Magenta,
+	PickupQuickHands:   colornames.Lime,
+}
+
+func (k PickupKind) String() string {
+	if int(k) < len(pickupNames) {
+		return pickupNames[k]
+	}
+	return fmt.Sprintf("PickupKind(%d)", k)
+}
+
+// PickupRules define when and where pickups appear.
+type PickupRules struct {
+	Lifetime   float64     `json:"lifetime"`   // seconds a pickup lies on the ground
+	Blink      float64     `json:"blink"`      // the pickup blinks during this many last seconds
+	Every      float64     `json:"every"`      // a random pickup appears somewhere every this many seconds, 0 disables
+	DropChance float64     `json:"dropChance"` // chance for a killed enemy to drop a pickup
+	Points     []pixel.Vec `json:"-"`          // fixed spots which get a new pickup PointEvery seconds after the last one is gone
+	PointEvery float64     `json:"pointEvery"`
+}
+
+type Pickup struct {
+	Entity
+	Kind  PickupKind
+	dieAt float64
+	point int // index of the fixed spot or -1
+}
+
+// Pickups is the pool of all pickups in the world.
+type Pickups struct {
+	Rules   PickupRules
+	pool    []Pickup
+	sprites [numberOfPickupKinds]*pixel.Sprite
+
+	nextTimed  float64
+	pointTaken []bool
+	pointNext  []float64
+
+	note    string
+	noteEnd float64
+}
+
+const maxPickups = 16
+
+func NewPickups(sprites [numberOfPickupKinds]*pixel.Sprite, rules PickupRules) *Pickups {
+	p := &Pickups{
+		Rules:      rules,
+		pool:       make([]Pickup, maxPickups),
+		sprites:    sprites,
+		nextTimed:  engine.elapsed + rules.Every,
+		pointTaken: make([]bool, len(rules.Points)),
+		pointNext:  make([]float64, len(rules.Points)),
+	}
+	r := pixel.R(-3, -3, 3, 3)
+	for i := range p.pool {
+		p.pool[i].Entity = *NewEntity(sprites[0], pixel.ZV)
+		p.pool[i].Collider = &r
+		p.pool[i].Deactivate()
+	}
+	for i := range p.pointNext {
+		p.pointNext[i] = engine.elapsed + rules.PointEvery
+	}
+	return p
+}
+
+// Spawn puts a pickup of the kind at pos. It returns false when the pool is full.
+func (p *Pickups) Spawn(kind PickupKind, pos pixel.Vec) bool {
+	return p.spawn(kind, pos, -1)
+}
+
+func (p *Pickups) spawn(kind PickupKind, pos pixel.Vec, point int) bool {
+	for i := range p.pool {
+		pk := &p.pool[i]
+		if pk.Active {
+			continue
+		}
+		pk.Kind = kind
+		pk.Sprite = p.sprites[kind]
+		pk.Color = pickupColors[kind]
+		pk.Pos = pos
+		pk.dieAt = engine.elapsed + p.Rules.Lifetime
+		pk.point = point
+		pk.Activate()
+		return true
+	}
+	return false
+}
+
+// Drop may leave a random pickup where an enemy died.
+func (p *Pickups) Drop(pos pixel.Vec) {
+	if rand.Float64() < p.Rules.DropChance {
+		p.Spawn(randomPickupKind(), pos)
+	}
+}
+
+func randomPickupKind() PickupKind {
+	return PickupKind(rand.Intn(int(numberOfPickupKinds)))
+}
+
+// Update spawns pickups by the rules, removes expired ones and calls collect
+// for every pickup the hero touches.
+func (p *Pickups) Update(collect func(kind PickupKind)) {
+	if p.Rules.Every > 0 && engine.elapsed > p.nextTimed {
+		p.nextTimed = engine.elapsed + p.Rules.Every
+		p.Spawn(randomPickupKind(), farSpot())
+	}
+	for i, pos := range p.Rules.Points {
+		if !p.pointTaken[i] && engine.elapsed > p.pointNext[i] {
+			p.pointTaken[i] = p.spawn(randomPickupKind(), pos, i)
+		}
+	}
+
+	heroCol := hero.AbsCollider()
+	for i := range p.pool {
+		pk := &p.pool[i]
+		if !pk.Active {
+			continue
+		}
+		collected := hero.Alive() && collides(heroCol, pk.AbsCollider())
+		if !collected && engine.elapsed < pk.dieAt {
+			left := pk.dieAt - engine.elapsed
+			// Blink faster as the end comes closer.
+			pk.Visible = left > p.Rules.Blink || math.Sin(engine.elapsed*(30-20*left/p.Rules.Blink)) > 0
+			pk.ScaleXY = pixel.V(1, 1).Scaled(0.8 + 0.15*math.Sin(engine.elapsed*5))
+			continue
+		}
+		pk.Deactivate()
+		if pk.point >= 0 {
+			p.pointTaken[pk.point] = false
+			p.pointNext[pk.point] = engine.elapsed + p.Rules.PointEvery
+		}
+		if collected {
+			collect(pk.Kind)
+		}
+	}
+}
+
+// Notify shows the message about a collected pickup.
+func (p *Pickups) Notify(format string, args ...interface{}) {
+	p.note = fmt.Sprintf(format, args...)
+	p.noteEnd = engine.elapsed + 2
+}
+
+// Notification returns the message about the last collected pickup, if any.
+func (p *Pickups) Notification() string {
+	if engine.elapsed > p.noteEnd {
+		return ""
+	}
+	return p.note
+}
+
+func (p *Pickups) Draw(t pixel.Target) {
+	for i := range p.pool {
+		p.pool[i].Draw(t)
+	}
+}
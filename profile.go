@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// profileVersion is the version of the profile schema written by this build.
+const profileVersion = 2
+
+// profileMigrations[v-1] upgrades a decoded profile of version v to v+1.
+// Bump profileVersion and append a migration whenever a field is renamed,
+// removed or changes its meaning; new fields with a zero default need none.
+var profileMigrations = []func(raw map[string]interface{}) error{
+	// Version 2 turned hero variants into classes chosen on the title
+	// screen, the variant is not a setting anymore.
+	func(raw map[string]interface{}) error {
+		if s, ok := raw["settings"].(map[string]interface{}); ok {
+			delete(s, "variant")
+		}
+		return nil
+	},
+}
+
+// Profile keeps the progress of the player between runs.
+type Profile struct {
+	Version  int           `json:"version"`
+	Stats    LifetimeStats `json:"stats"`
+	Bank     int           `json:"bank"` // score which is not spent on unlocks yet
+	Unlocked []string      `json:"unlocked"`
+	Settings Settings      `json:"settings"`
+
+	path string
+}
+
+type LifetimeStats struct {
+	Runs        int     `json:"runs"`
+	Kills       int     `json:"kills"`
+	Shots       int     `json:"shots"`
+	BossesSlain int     `json:"bossesSlain"`
+	TotalScore  int     `json:"totalScore"`
+	BestScore   int     `json:"bestScore"`
+	BestCombo   int     `json:"bestCombo"`
+	PlayTime    float64 `json:"playTime"` // seconds
+}
+
+// RunStats is what a single run adds to the lifetime stats.
+type RunStats struct {
+	Score, Kills, Shots, Bosses, BestCombo int
+	Time                                   float64
+}
+
+// Settings are remembered from the command line of the last run.
+type Settings struct {
+	VSync        bool `json:"vsync"`
+	AimAssist    bool `json:"aimAssist"`
+	Trajectory   bool `json:"trajectory"`
+	ActiveReload bool `json:"activeReload"`
+	DashCancel   bool `json:"dashCancel"`
+}
+
+// configDir returns the directory for configuration of the user, the same
+// as os.UserConfigDir which is not available before Go 1.13.
+func configDir() (string, error) {
+	switch runtime.GOOS {
+	case "windows":
+		if dir := os.Getenv("AppData"); dir != "" {
+			return dir, nil
+		}
+		return "", errors.New("%AppData% is not defined")
+	case "darwin":
+		if home := os.Getenv("HOME"); home != "" {
+			return filepath.Join(home, "Library", "Application Support"), nil
+		}
+		return "", errors.New("$HOME is not defined")
+	default:
+		if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+			return dir, nil
+		}
+		if home := os.Getenv("HOME"); home != "" {
+			return filepath.Join(home, ".config"), nil
+		}
+		return "", errors.New("neither $XDG_CONFIG_HOME nor $HOME are defined")
+	}
+}
+
+// DefaultProfilePath returns where the profile lives in the config directory.
+func DefaultProfilePath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "aworld", "profile.json"), nil
+}
+
+func NewProfile(path string) *Profile {
+	return &Profile{Version: profileVersion, path: path}
+}
+
+// LoadProfile reads the profile at path migrating it to the current schema.
+// A missing file gives a new profile.
+func LoadProfile(path string) (*Profile, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return NewProfile(path), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	version, _ := raw["version"].(float64)
+	v := int(version)
+	if v < 1 || v > profileVersion {
+		return nil, fmt.Errorf("%s: unsupported profile version %v", path, raw["version"])
+	}
+	for ; v < profileVersion; v++ {
+		if err := profileMigrations[v-1](raw); err != nil {
+			return nil, fmt.Errorf("%s: migrating from version %d: %v", path, v, err)
+		}
+		raw["version"] = v + 1
+	}
+	if data, err = json.Marshal(raw); err != nil {
+		return nil, err
+	}
+
+	p := NewProfile(path)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(p); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := p.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return p, nil
+}
+
+func (p *Profile) validate() error {
+	if p.Bank < 0 {
+		return errors.New("bank must not be negative")
+	}
+	for _, id := range p.Unlocked {
+		if findUnlock(id) == nil {
+			return fmt.Errorf("unknown unlock %q", id)
+		}
+	}
+	return nil
+}
+
+// Save writes the profile to a temporary file and renames it over the old
+// one, so a crash leaves either the old or the new profile behind.
+func (p *Profile) Save() error {
+	data, err := json.MarshalIndent(p, "", "\t")
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(p.path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, ".profile-*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), p.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// Record adds the run to the lifetime stats and its score to the bank.
+func (p *Profile) Record(r RunStats) {
+	s := &p.Stats
+	s.Runs++
+	s.Kills += r.Kills
+	s.Shots += r.Shots
+	s.BossesSlain += r.Bosses
+	s.TotalScore += r.Score
+	s.PlayTime += r.Time
+	if r.Score > s.BestScore {
+		s.BestScore = r.Score
+	}
+	if r.BestCombo > s.BestCombo {
+		s.BestCombo = r.BestCombo
+	}
+	p.Bank += r.Score
+}
+
+type UnlockKind uint8
+
+const (
+	// UnlockArrow puts an arrow of the type into the quiver at start.
+	UnlockArrow UnlockKind = iota
+	// UnlockClass makes the hero class with the same ID selectable.
+	UnlockClass
+)
+
+// Unlock is bought once with the score in the bank of the profile.
+type Unlock struct {
+	ID    string
+	Name  string
+	Kind  UnlockKind
+	Cost  int
+	Arrow ArrowType // for UnlockArrow
+}
+
+var unlocks = []Unlock{
+	{ID: "steel", Name: "Start with a steel arrow", Kind: UnlockArrow, Cost: 10000, Arrow: ArrowSteel},
+	{ID: "fire", Name: "Start with a fire arrow", Kind: UnlockArrow, Cost: 30000, Arrow: ArrowFire},
+	{ID: "swift", Name: "Swift class: runs and dashes more, rushes to refill stamina", Kind: UnlockClass, Cost: 20000},
+	{ID: "sturdy", Name: "Sturdy class: more health, shields itself with a bulwark", Kind: UnlockClass, Cost: 20000},
+}
+
+func findUnlock(id string) *Unlock {
+	for i := range unlocks {
+		if unlocks[i].ID == id {
+			return &unlocks[i]
+		}
+	}
+	return nil
+}
+
+func (p *Profile) Has(id string) bool {
+	for _, u := range p.Unlocked {
+		if u == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Buy spends the bank on the unlock.
+func (p *Profile) Buy(id string) error {
+	u := findUnlock(id)
+	switch {
+	case u == nil:
+		return fmt.Errorf("unknown unlock %q", id)
+	case p.Has(id):
+		return fmt.Errorf("%q is already unlocked", id)
+	case p.Bank < u.Cost:
+		return fmt.Errorf("%q costs %d, the bank holds only %d", id, u.Cost, p.Bank)
+	}
+	p.Bank -= u.Cost
+	p.Unlocked = append(p.Unlocked, id)
+	return nil
+}
+
+// Report writes the stats and the unlocks of the profile.
+func (p *Profile) Report(w io.Writer) {
+	s := &p.Stats
+	fmt.Fprintf(w, "runs: %d, kills: %d, shots: %d, kings slain: %d\n", s.Runs, s.Kills, s.Shots, s.BossesSlain)
+	fmt.Fprintf(w, "best score: %d, best combo: x%d, played: %.0f min\n", s.BestScore, s.BestCombo, s.PlayTime/60)
+	fmt.Fprintf(w, "bank: %d\n\n", p.Bank)
+	for _, u := range unlocks {
+		state := fmt.Sprintf("%6d", u.Cost)
+		if p.Has(u.ID) {
+			state = "  owned"
+		}
+		fmt.Fprintf(w, "%s  %-7s %s\n", state, u.ID, u.Name)
+	}
+}
+
+// Equip gives the unlocked arrows to the quiver.
+func (p *Profile) Equip(q *Quiver) {
+	for _, id := range p.Unlocked {
+		if u := findUnlock(id); u.Kind == UnlockArrow {
+			q.Upgrade(1, u.Arrow)
+		}
+	}
+}
+
+// Allows returns true when the class needs no unlock or it is unlocked.
+// Without a profile every class is allowed.
+func (p *Profile) Allows(c *HeroClass) bool {
+	u := findUnlock(c.ID)
+	return p == nil || u == nil || u.Kind != UnlockClass || p.Has(u.ID)
+}
+
+// settingFlags ties the settings of the profile to the command line flags.
+func (p *Profile) settingFlags() map[string]*bool {
+	return map[string]*bool{
+		"vsync":        &p.Settings.VSync,
+		"aimassist":    &p.Settings.AimAssist,
+		"trajectory":   &p.Settings.Trajectory,
+		"activereload": &p.Settings.ActiveReload,
+		"dashcancel":   &p.Settings.DashCancel,
+	}
+}
+
+// SyncSettings remembers the flags given on the command line and fills
+// the other ones from the profile.
+func (p *Profile) SyncSettings() {
+	given := flagsGiven()
+	for name, setting := range p.settingFlags() {
+		value := flag.Lookup(name).Value.(flag.Getter).Get().(bool)
+		if given[name] {
+			*setting = value
+		} else {
+			flag.Set(name, fmt.Sprint(*setting))
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+// Projectile is a blob shot by an enemy. It flies straight until it hits
+// the hero or a wall, or until its time is over.
+type Projectile struct {
+	Entity
+	vel    pixel.Vec
+	damage float64
+	dieAt  float64
+}
+
+// Projectiles is the pool of all enemy projectiles.
+type Projectiles struct {
+	pool []Projectile
+	next int // where to look for a free projectile first
+}
+
+// Projectiles live this many seconds if they hit nothing.
+const projectileTTL = 4.0
+
+// Every spit poisons the hero for this damage per second, poison stacks.
+const spitPoison = 1.5
+
+func NewProjectiles(spr *pixel.Sprite, size int) *Projectiles {
+	p := &Projectiles{pool: make([]Projectile, size)}
+	// Collider is smaller than the sprite so close calls can be dodged.
+	r := pixel.R(-1.5, -1.5, 1.5, 1.5)
+	for i := range p.pool {
+		p.pool[i].Entity = *NewEntity(spr, pixel.ZV)
+		p.pool[i].ScaleXY = pixel.V(0.5, 0.5)
+		p.pool[i].Collider = &r
+		p.pool[i].Deactivate()
+	}
+	return p
+}
+
+// Fire launches a projectile from pos with velocity vel. It returns false
+// when all projectiles of the pool are in the air.
+func (p *Projectiles) Fire(pos, vel pixel.Vec, damage float64, col color.RGBA) bool {
+	for i := 0; i < len(p.pool); i++ {
+		idx := (p.next + i) % len(p.pool)
+		pr := &p.pool[idx]
+		if pr.Active {
+			continue
+		}
+		pr.Pos = pos
+		pr.vel = vel
+		pr.damage = damage
+		pr.dieAt = engine.elapsed + projectileTTL
+		pr.Color = col
+		pr.Activate()
+		p.next = idx + 1
+		return true
+	}
+	return false
+}
+
+// Aimed fires one projectile from pos straight at target.
+func (p *Projectiles) Aimed(pos, target pixel.Vec, speed, damage float64, col color.RGBA) {
+	p.Fire(pos, target.Sub(pos).Unit().Scaled(speed), damage, col)
+}
+
+// Spread fires n projectiles at target fanned out evenly over the angle.
+func (p *Projectiles) Spread(pos, target pixel.Vec, n int, angle, speed, damage float64, col color.RGBA) {
+	if n == 1 {
+		p.Aimed(pos, target, speed, damage, col)
+		return
+	}
+	base := target.Sub(pos).Angle() - angle/2
+	for i := 0; i < n; i++ {
+		a := base + angle*float64(i)/float64(n-1)
+		p.Fire(pos, pixel.Unit(a).Scaled(speed), damage, col)
+	}
+}
+
+// Radial fires n projectiles evenly all around pos, the first one at angle offset.
+func (p *Projectiles) Radial(pos pixel.Vec, n int, offset, speed, damage float64, col color.RGBA) {
+	for i := 0; i < n; i++ {
+		a := offset + 2*math.Pi*float64(i)/float64(n)
+		p.Fire(pos, pixel.Unit(a).Scaled(speed), damage, col)
+	}
+}
+
+func (p *Projectiles) Update() {
+	heroCol := hero.AbsCollider()
+	for i := range p.pool {
+		pr := &p.pool[i]
+		if !pr.Active {
+			continue
+		}
+		pr.Pos = pr.Pos.Add(pr.vel.Scaled(engine.dt))
+		pr.Angle += 6 * engine.dt
+		col := pr.AbsCollider()
+		if hero.Alive() && collides(col, heroCol) {
+			hero.Damage(-pr.damage)
+			hero.Status.Apply(StatusPoison, spitPoison, 3)
+			pr.Deactivate()
+			continue
+		}
+		if engine.elapsed > pr.dieAt {
+			pr.Deactivate()
+			continue
+		}
+		for _, wall := range world.GetColliders(col) {
+			if collides(col, wall) {
+				pr.Deactivate()
+				break
+			}
+		}
+	}
+}
+
+// Clear removes all projectiles from the air.
+func (p *Projectiles) Clear() {
+	for i := range p.pool {
+		p.pool[i].Deactivate()
+	}
+}
+
+func (p *Projectiles) Draw(t pixel.Target) {
+	for i := range p.pool {
+		p.pool[i].Draw(t)
+	}
+}
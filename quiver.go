@@ -0,0 +1,294 @@
+package main
+
+import (
+	"math"
+	"sort"
+
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
+	"golang.org/x/image/colornames"
+)
+
+// Quiver owns all arrows of the hero: the ones in the quiver, in hands,
+// flying through the air and stuck in the ground.
+type Quiver struct {
+	arrows []*Arrow // every arrow which belongs to the hero, including broken
+	stock  []*Arrow // arrows in the quiver, the last one is drawn first
+	inHand *Arrow
+
+	Capacity    int     // maximum number of arrows the hero can own
+	DrawTime    float64 // seconds to move the arrow from the quiver to hands
+	BreakChance float64 // chance for an arrow to break when it hits something
+	PierceBonus int     // enemies every arrow goes through in addition to its own pierce
+	Ricochet    int     // times every arrow bounces to the next enemy
+	ReturnTime  float64 // stuck arrows return to the quiver after this many seconds, 0 never
+
+	// Active reload lets the hero press reload while drawing the arrow.
+	// A press inside the sweet spot finishes drawing at once and gives the arrow
+	// bonus damage, a press outside of it delays the drawing.
+	ActiveReload bool
+	SweetStart   float64 // start of the sweet spot as a part of DrawTime
+	SweetEnd     float64 // end of the sweet spot as a part of DrawTime
+	MissPenalty  float64 // seconds added to the drawing on a mistimed press
+	DamageBonus  float64 // damage multiplier of the perfectly reloaded arrow
+
+	// Holding the shot charges the arrow in hands in ChargeTime seconds, the
+	// fully charged arrow deals ChargeDamage times more damage and flies faster.
+	ChargeTime   float64
+	ChargeDamage float64
+	charge       float64 // 0 ... 1
+
+	drawDone    float64 // when the drawing of the next arrow is finished
+	reloadTried bool    // only one reload attempt per drawing is allowed
+	perfect     bool    // the arrow being drawn gets the damage bonus
+
+	sprArrow, sprStuck *pixel.Sprite
+}
+
+func NewQuiver(normal, stuck *pixel.Sprite, capacity int, drawTime, breakChance float64) *Quiver {
+	q := &Quiver{
+		Capacity:    capacity,
+		DrawTime:    drawTime,
+		BreakChance: breakChance,
+		SweetStart:  0.45,
+		SweetEnd:    0.65,
+		MissPenalty: 0.5,
+		DamageBonus: 2,
+		sprArrow:    normal,
+		sprStuck:    stuck,
+	}
+	for i := 0; i < capacity; i++ {
+		q.Add(ArrowWooden)
+	}
+	q.startDraw()
+	return q
+}
+
+// ReloadResult is the outcome of the active reload attempt.
+type ReloadResult uint8
+
+const (
+	ReloadIgnored ReloadResult = iota
+	ReloadPerfect
+	ReloadMissed
+)
+
+// drawTime returns DrawTime changed by the buffs of the hero.
+func (q *Quiver) drawTime() float64 {
+	return hero.Buffs.Stat(StatDrawTime, q.DrawTime)
+}
+
+func (q *Quiver) startDraw() {
+	q.drawDone = engine.elapsed + q.drawTime()
+	q.reloadTried = false
+	q.perfect = false
+}
+
+// CancelDraw starts drawing the next arrow over again.
+func (q *Quiver) CancelDraw() {
+	if q.Drawing() {
+		q.startDraw()
+	}
+}
+
+// Drawing returns true while the hero is drawing an arrow from the quiver.
+func (q *Quiver) Drawing() bool {
+	return q.inHand == nil && len(q.stock) > 0
+}
+
+// DrawProgress returns how much of the drawing is done, in range 0 ... 1.
+// Penalties of mistimed reloads may push it below zero.
+func (q *Quiver) DrawProgress() float64 {
+	t := q.drawTime()
+	if t <= 0 {
+		return 1
+	}
+	return pixel.Clamp(1-(q.drawDone-engine.elapsed)/t, -1, 1)
+}
+
+// Reload is the active reload attempt.
+func (q *Quiver) Reload() ReloadResult {
+	if !q.ActiveReload || !q.Drawing() || q.reloadTried {
+		return ReloadIgnored
+	}
+	q.reloadTried = true
+	p := q.DrawProgress()
+	if p >= q.SweetStart && p <= q.SweetEnd {
+		q.drawDone = engine.elapsed
+		q.perfect = true
+		return ReloadPerfect
+	}
+	q.drawDone += q.MissPenalty
+	return ReloadMissed
+}
+
+// Arrows returns all arrows of the quiver, the inactive ones included.
+func (q *Quiver) Arrows() []*Arrow {
+	return q.arrows
+}
+
+// Owned returns the number of arrows which are not broken.
+func (q *Quiver) Owned() int {
+	n := 0
+	for _, a := range q.arrows {
+		if a.State != ArrowInactive {
+			n++
+		}
+	}
+	return n
+}
+
+// Stock returns the number of arrows in the quiver.
+func (q *Quiver) Stock() int {
+	return len(q.stock)
+}
+
+// InHand returns the arrow that is ready to be shot or nil.
+func (q *Quiver) InHand() *Arrow {
+	return q.inHand
+}
+
+// Add puts a new arrow of type t into the quiver unless the quiver is full.
+// Broken arrows are reused before allocating new ones.
+func (q *Quiver) Add(t ArrowType) bool {
+	if q.Owned() >= q.Capacity {
+		return false
+	}
+	var a *Arrow
+	if free := firstFreeArrow(q.arrows); free != -1 {
+		a = q.arrows[free]
+	} else {
+		a = NewArrow(q.sprArrow, q.sprStuck)
+		q.arrows = append(q.arrows, a)
+	}
+	a.SetType(t, q.BreakChance)
+	q.store(a)
+	return true
+}
+
+// Upgrade raises the capacity of the quiver by n and fills it with arrows of type t.
+func (q *Quiver) Upgrade(n int, t ArrowType) {
+	q.Capacity += n
+	for q.Add(t) {
+	}
+}
+
+// store puts arrow a into the quiver keeping the stock ordered by the arrow type,
+// so the best arrows are drawn first.
+func (q *Quiver) store(a *Arrow) {
+	if len(q.stock) == 0 && q.inHand == nil {
+		q.startDraw()
+	}
+	a.ToQuiver()
+	q.stock = append(q.stock, a)
+	sort.SliceStable(q.stock, func(i, j int) bool {
+		return q.stock[i].Type < q.stock[j].Type
+	})
+}
+
+func (q *Quiver) Update() {
+	for _, a := range q.arrows {
+		if a.Active {
+			a.Update()
+		}
+		if q.ReturnTime > 0 && a.State == ArrowStuck && a.StuckFor() > q.ReturnTime {
+			q.store(a)
+		}
+	}
+
+	if q.inHand == nil && engine.elapsed >= q.drawDone {
+		// Move arrow from the quiver to hands.
+		if len(q.stock) > 0 {
+			last := len(q.stock) - 1
+			q.inHand = q.stock[last]
+			q.stock = q.stock[:last]
+			q.inHand.ToHands()
+			q.inHand.Damage = 1
+			if q.perfect {
+				q.inHand.Damage = q.DamageBonus
+			}
+		}
+	}
+	if q.inHand != nil {
+		q.inHand.Speed = hero.Buffs.Stat(StatArrowSpeed, tuning.Arrow.Speed)
+	}
+}
+
+// Shoot releases the arrow in hands towards the target.
+func (q *Quiver) Shoot(from, to, relational pixel.Vec) bool {
+	if q.inHand == nil {
+		return false
+	}
+	q.inHand.Damage *= 1 + (q.ChargeDamage-1)*q.charge
+	q.inHand.Damage = hero.Buffs.Stat(StatDamage, q.inHand.Damage)
+	q.inHand.Speed *= 1 + tuning.Arrow.ChargeSpeed*q.charge
+	q.inHand.Fly(from, to, relational)
+	q.inHand.pierceLeft += q.PierceBonus
+	q.inHand.ricochetLeft = q.Ricochet
+	q.inHand = nil
+	q.charge = 0
+	if len(q.stock) > 0 {
+		q.startDraw()
+	}
+	return true
+}
+
+// Charge charges the arrow in hands for the current frame.
+func (q *Quiver) Charge() {
+	if q.inHand != nil && q.ChargeTime > 0 {
+		q.charge = math.Min(1, q.charge+engine.dt/q.ChargeTime)
+	}
+}
+
+// Uncharge drops the charge of the arrow in hands.
+func (q *Quiver) Uncharge() {
+	q.charge = 0
+}
+
+// Charged returns how much the arrow in hands is charged, in range 0 ... 1.
+func (q *Quiver) Charged() float64 {
+	return q.charge
+}
+
+// Collect picks up stuck arrows touching the collider.
+func (q *Quiver) Collect(col pixel.Rect) {
+	for _, a := range q.arrows {
+		if a.State == ArrowStuck && collides(a.AbsCollider(), col) {
+			q.store(a)
+		}
+	}
+}
+
+// Attach places arrows in the quiver and in hands around the hero.
+func (q *Quiver) Attach(pos, target pixel.Vec) {
+	if q.inHand != nil {
+		q.inHand.AttachToHands(pos, target)
+		// The charged arrow is pulled back.
+		q.inHand.Pos = q.inHand.Pos.Sub(target.Sub(pos).Unit().Scaled(3 * q.charge))
+	}
+	for i, a := range q.stock {
+		a.AttachToQuiver(pos, i, len(q.stock))
+	}
+}
+
+// DrawReload draws the progress of the drawing as a small bar under pos with
+// the sweet spot of active reload marked on it.
+func (q *Quiver) DrawReload(imd *imdraw.IMDraw, pos pixel.Vec) {
+	if !q.ActiveReload || !q.Drawing() {
+		return
+	}
+	const w, h = 12.0, 1.5
+	min := pos.Add(pixel.V(-w/2, -11))
+	imd.Color = colornames.Dimgray
+	imd.Push(min, min.Add(pixel.V(w, h)))
+	imd.Rectangle(0)
+	if !q.reloadTried {
+		imd.Color = colornames.Limegreen
+		imd.Push(min.Add(pixel.V(w*q.SweetStart, 0)), min.Add(pixel.V(w*q.SweetEnd, h)))
+		imd.Rectangle(0)
+	}
+	x := w * pixel.Clamp(q.DrawProgress(), 0, 1)
+	imd.Color = colornames.White
+	imd.Push(min.Add(pixel.V(x, -0.5)), min.Add(pixel.V(x, h+0.5)))
+	imd.Line(0.7)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+// SpatialGrid is a uniform grid over the world used as a broadphase for
+// collisions between entities. Items are identified by ints, usually indices
+// into a slice owned by the caller. The grid is meant to be cleared and
+// filled again every tick.
+type SpatialGrid struct {
+	bounds   pixel.Rect
+	cellSize float64
+	cols     int
+	rows     int
+	cells    [][]int
+
+	rects []pixel.Rect // collider of every item by id
+
+	// seen marks items already returned by the current query, so items
+	// spanning several cells are reported once.
+	seen  []uint32
+	stamp uint32
+}
+
+func NewSpatialGrid(bounds pixel.Rect, cellSize float64) *SpatialGrid {
+	g := &SpatialGrid{bounds: bounds, cellSize: cellSize}
+	g.cols = int(math.Ceil(bounds.W()/cellSize)) + 1
+	g.rows = int(math.Ceil(bounds.H()/cellSize)) + 1
+	g.cells = make([][]int, g.cols*g.rows)
+	return g
+}
+
+// cell returns the column and the row of the cell containing v, points outside
+// of the grid bounds belong to the border cells.
+func (g *SpatialGrid) cell(v pixel.Vec) (int, int) {
+	x := int((v.X - g.bounds.Min.X) / g.cellSize)
+	y := int((v.Y - g.bounds.Min.Y) / g.cellSize)
+	if x < 0 {
+		x = 0
+	} else if x >= g.cols {
+		x = g.cols - 1
+	}
+	if y < 0 {
+		y = 0
+	} else if y >= g.rows {
+		y = g.rows - 1
+	}
+	return x, y
+}
+
+func (g *SpatialGrid) Clear() {
+	for i := range g.cells {
+		g.cells[i] = g.cells[i][:0]
+	}
+}
+
+// Insert adds item id with collider r to all cells it overlaps.
+func (g *SpatialGrid) Insert(id int, r pixel.Rect) {
+	for id >= len(g.rects) {
+		g.rects = append(g.rects, pixel.Rect{})
+		g.seen = append(g.seen, 0)
+	}
+	g.rects[id] = r
+	x1, y1 := g.cell(r.Min)
+	x2, y2 := g.cell(r.Max)
+	for x := x1; x <= x2; x++ {
+		for y := y1; y <= y2; y++ {
+			c := y*g.cols + x
+			g.cells[c] = append(g.cells[c], id)
+		}
+	}
+}
+
+func (g *SpatialGrid) nextStamp() uint32 {
+	g.stamp++
+	if g.stamp == 0 {
+		// The counter wrapped around, old marks may look fresh now.
+		for i := range g.seen {
+			g.seen[i] = 0
+		}
+		g.stamp = 1
+	}
+	return g.stamp
+}
+
+// QueryRect appends to buf ids of all items whose colliders overlap r.
+func (g *SpatialGrid) QueryRect(r pixel.Rect, buf []int) []int {
+	stamp := g.nextStamp()
+	x1, y1 := g.cell(r.Min)
+	x2, y2 := g.cell(r.Max)
+	for x := x1; x <= x2; x++ {
+		for y := y1; y <= y2; y++ {
+			for _, id := range g.cells[y*g.cols+x] {
+				if g.seen[id] == stamp {
+					continue
+				}
+				g.seen[id] = stamp
+				if collides(r, g.rects[id]) {
+					buf = append(buf, id)
+				}
+			}
+		}
+	}
+	return buf
+}
+
+// QueryRadius appends to buf ids of all items whose centers are within
+// radius from pos.
+func (g *SpatialGrid) QueryRadius(pos pixel.Vec, radius float64, buf []int) []int {
+	box := pixel.R(pos.X-radius, pos.Y-radius, pos.X+radius, pos.Y+radius)
+	start := len(buf)
+	buf = g.QueryRect(box, buf)
+	n := start
+	for _, id := range buf[start:] {
+		if g.rects[id].Center().Sub(pos).Len() <= radius {
+			buf[n] = id
+			n++
+		}
+	}
+	return buf[:n]
+}
+
+// Nearest returns the item with the center closest to pos within radius for
+// which accept returns true. It returns -1 if there is no such item.
+func (g *SpatialGrid) Nearest(pos pixel.Vec, radius float64, accept func(id int) bool) int {
+	best := -1
+	bestDist := math.Inf(1)
+	cx, cy := g.cell(pos)
+	// Look through rings of cells around pos until the ring is further than
+	// the best item found so far.
+	maxRing := int(math.Ceil(radius/g.cellSize)) + 1
+	stamp := g.nextStamp()
+	for ring := 0; ring <= maxRing; ring++ {
+		if best != -1 && float64(ring-1)*g.cellSize > bestDist {
+			break
+		}
+		for x := cx - ring; x <= cx+ring; x++ {
+			for y := cy - ring; y <= cy+ring; y++ {
+				onRing := x == cx-ring || x == cx+ring || y == cy-ring || y == cy+ring
+				if !onRing || x < 0 || y < 0 || x >= g.cols || y >= g.rows {
+					continue
+				}
+				for _, id := range g.cells[y*g.cols+x] {
+					if g.seen[id] == stamp {
+						continue
+					}
+					g.seen[id] = stamp
+					d := g.rects[id].Center().Sub(pos).Len()
+					if d <= radius && d < bestDist && (accept == nil || accept(id)) {
+						best = id
+						bestDist = d
+					}
+				}
+			}
+		}
+	}
+	return best
+}
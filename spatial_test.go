@@ -0,0 +1,242 @@
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/faiface/pixel"
+)
+
+var gridBounds = pixel.R(0, 0, 640, 400)
+
+// box returns a square collider of half size h centered at (x, y).
+func box(x, y, h float64) pixel.Rect {
+	return pixel.R(x-h, y-h, x+h, y+h)
+}
+
+func newTestGrid(rects []pixel.Rect) *SpatialGrid {
+	g := NewSpatialGrid(gridBounds, 32)
+	for i, r := range rects {
+		g.Insert(i, r)
+	}
+	return g
+}
+
+func sorted(ids []int) []int {
+	sort.Ints(ids)
+	if ids == nil {
+		ids = []int{}
+	}
+	return ids
+}
+
+func TestQueryRect(t *testing.T) {
+	rects := []pixel.Rect{
+		box(10, 10, 4),
+		pixel.R(20, 20, 100, 100), // spans many cells
+		box(600, 380, 4),
+		box(-50, -50, 4), // outside of the bounds
+		box(200, 200, 4),
+	}
+	g := newTestGrid(rects)
+	tests := []struct {
+		name string
+		r    pixel.Rect
+		want []int
+	}{
+		{"one cell", box(10, 10, 2), []int{0}},
+		{"spanning item reported once", pixel.R(0, 0, 120, 120), []int{0, 1}},
+		{"inside spanning item", box(60, 60, 1), []int{1}},
+		{"touching edges", pixel.R(100, 100, 110, 110), []int{1}},
+		{"far corner", box(610, 390, 20), []int{2}},
+		{"outside of the bounds", box(-50, -50, 10), []int{3}},
+		{"empty area", box(400, 100, 20), []int{}},
+		{"whole world", pixel.R(-100, -100, 700, 500), []int{0, 1, 2, 3, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sorted(g.QueryRect(tt.r, nil))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("QueryRect(%v) = %v, want %v", tt.r, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryRadius(t *testing.T) {
+	rects := []pixel.Rect{
+		box(100, 100, 4),
+		box(130, 100, 4),
+		box(121, 121, 4), // collider overlaps the query box, center is outside
+		box(300, 300, 4),
+	}
+	g := newTestGrid(rects)
+	tests := []struct {
+		name   string
+		pos    pixel.Vec
+		radius float64
+		want   []int
+	}{
+		{"center only", pixel.V(100, 100), 10, []int{0}},
+		{"on the radius", pixel.V(100, 100), 30, []int{0, 1, 2}},
+		{"colliders in the box, centers out", pixel.V(100, 100), 29, []int{0}},
+		{"nothing around", pixel.V(500, 100), 50, []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sorted(g.QueryRadius(tt.pos, tt.radius, nil))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("QueryRadius(%v, %v) = %v, want %v", tt.pos, tt.radius, got, tt.want)
+			}
+		})
+	}
+
+	// Results are appended after what is already in buf.
+	buf := g.QueryRadius(pixel.V(300, 300), 10, []int{7})
+	if !reflect.DeepEqual(buf, []int{7, 3}) {
+		t.Errorf("QueryRadius appended to [7] = %v, want [7 3]", buf)
+	}
+}
+
+func TestNearest(t *testing.T) {
+	tests := []struct {
+		name   string
+		items  []pixel.Vec
+		pos    pixel.Vec
+		radius float64
+		accept func(id int) bool
+		want   int
+		// Items in rings beyond the nearest one must not be looked at.
+		unvisited []int
+	}{
+		{
+			name:   "closer item in the next cell",
+			items:  []pixel.Vec{pixel.V(2, 16), pixel.V(34, 16)},
+			pos:    pixel.V(31, 16),
+			radius: 100,
+			want:   1,
+		},
+		{
+			name:   "closer item in the outer ring",
+			items:  []pixel.Vec{pixel.V(63, 63), pixel.V(80, 16)},
+			pos:    pixel.V(16, 16),
+			radius: 100,
+			want:   1,
+		},
+		{
+			name:      "stops after the ring of the nearest",
+			items:     []pixel.Vec{pixel.V(20, 16), pixel.V(300, 16), pixel.V(16, 300)},
+			pos:       pixel.V(16, 16),
+			radius:    1000,
+			want:      0,
+			unvisited: []int{1, 2},
+		},
+		{
+			name:   "out of radius",
+			items:  []pixel.Vec{pixel.V(100, 16)},
+			pos:    pixel.V(16, 16),
+			radius: 50,
+			want:   -1,
+		},
+		{
+			name:   "rejected item skipped",
+			items:  []pixel.Vec{pixel.V(20, 16), pixel.V(60, 16)},
+			pos:    pixel.V(16, 16),
+			radius: 100,
+			accept: func(id int) bool { return id != 0 },
+			want:   1,
+		},
+		{
+			name:   "empty grid",
+			pos:    pixel.V(16, 16),
+			radius: 1000,
+			want:   -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rects := make([]pixel.Rect, len(tt.items))
+			for i, p := range tt.items {
+				rects[i] = box(p.X, p.Y, 2)
+			}
+			g := newTestGrid(rects)
+			if got := g.Nearest(tt.pos, tt.radius, tt.accept); got != tt.want {
+				t.Errorf("Nearest(%v, %v) = %d, want %d", tt.pos, tt.radius, got, tt.want)
+			}
+			for _, id := range tt.unvisited {
+				if g.seen[id] == g.stamp {
+					t.Errorf("Nearest looked at item %d beyond the nearest ring", id)
+				}
+			}
+		})
+	}
+}
+
+// movingRects returns n colliders scattered over the grid bounds and their
+// velocities.
+func movingRects(n int) ([]pixel.Rect, []pixel.Vec) {
+	r := rand.New(rand.NewSource(1))
+	rects := make([]pixel.Rect, n)
+	vels := make([]pixel.Vec, n)
+	for i := range rects {
+		rects[i] = box(r.Float64()*gridBounds.W(), r.Float64()*gridBounds.H(), 6)
+		vels[i] = pixel.V(r.Float64()-0.5, r.Float64()-0.5)
+	}
+	return rects, vels
+}
+
+// benchPairs keeps the benchmarks from optimizing the pair counting away.
+var benchPairs int
+
+func move(rects []pixel.Rect, vels []pixel.Vec) {
+	for i := range rects {
+		rects[i] = rects[i].Moved(vels[i])
+	}
+}
+
+// BenchmarkGrid finds all colliding pairs of 1000 moving entities per tick
+// through the grid.
+func BenchmarkGrid(b *testing.B) {
+	rects, vels := movingRects(1000)
+	g := NewSpatialGrid(gridBounds, 32)
+	var buf []int
+	pairs := 0
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		move(rects, vels)
+		g.Clear()
+		for i, r := range rects {
+			g.Insert(i, r)
+		}
+		for i, r := range rects {
+			buf = g.QueryRect(r, buf[:0])
+			for _, j := range buf {
+				if j > i {
+					pairs++
+				}
+			}
+		}
+	}
+	benchPairs = pairs
+}
+
+// BenchmarkAllPairs finds all colliding pairs of 1000 moving entities per
+// tick by checking every pair.
+func BenchmarkAllPairs(b *testing.B) {
+	rects, vels := movingRects(1000)
+	pairs := 0
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		move(rects, vels)
+		for i := range rects {
+			for j := i + 1; j < len(rects); j++ {
+				if collides(rects[i], rects[j]) {
+					pairs++
+				}
+			}
+		}
+	}
+	benchPairs = pairs
+}
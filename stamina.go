@@ -0,0 +1,104 @@
+package main
+
+import (
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
+	"golang.org/x/image/colornames"
+)
+
+type StaminaTuning struct {
+	Max         float64 `json:"max"`
+	Regen       float64 `json:"regen"`       // per second while the hero stands still
+	RegenDelay  float64 `json:"regenDelay"`  // seconds after spending before it comes back
+	Sprint      float64 `json:"sprint"`      // per second of sprinting
+	SprintSpeed float64 `json:"sprintSpeed"` // multiplies the speed of the hero
+	Dash        float64 `json:"dash"`        // per dash
+	Charge      float64 `json:"charge"`      // per second of holding a charged draw
+	ExhaustTime float64 `json:"exhaustTime"` // seconds of slowdown after running out
+	ExhaustSlow float64 `json:"exhaustSlow"` // part of the speed lost while exhausted
+}
+
+// Stamina is spent on sprinting, dashing and charging arrows and comes back
+// while the hero stands still and spends none.
+type Stamina struct {
+	StaminaTuning
+	Value          float64
+	exhaustedUntil float64
+	spentAt        float64
+}
+
+func NewStamina(t StaminaTuning) Stamina {
+	return Stamina{StaminaTuning: t, Value: t.Max}
+}
+
+// Spend takes amount at once. It returns false and takes nothing when there
+// is not enough stamina or the hero is exhausted.
+func (s *Stamina) Spend(amount float64) bool {
+	if s.Exhausted() || s.Value < amount {
+		return false
+	}
+	s.Value -= amount
+	s.spentAt = engine.elapsed
+	return true
+}
+
+// Drain takes rate per second for the current frame. Running out exhausts
+// the hero. It returns false when there was nothing to take.
+func (s *Stamina) Drain(rate float64) bool {
+	if s.Exhausted() || s.Value <= 0 {
+		return false
+	}
+	s.Value -= rate * engine.dt
+	s.spentAt = engine.elapsed
+	if s.Value <= 0 {
+		s.Value = 0
+		s.exhaustedUntil = engine.elapsed + s.ExhaustTime
+	}
+	return true
+}
+
+// Regenerate gives stamina back for the current frame, unless some was spent
+// within the last RegenDelay seconds.
+func (s *Stamina) Regenerate() {
+	if engine.elapsed-s.spentAt <= s.RegenDelay {
+		return
+	}
+	s.Value = pixel.Clamp(s.Value+s.Regen*engine.dt, 0, s.Max)
+}
+
+// Refill fills the stamina up and ends the exhaustion.
+func (s *Stamina) Refill() {
+	s.Value = s.Max
+	s.exhaustedUntil = 0
+}
+
+func (s *Stamina) Exhausted() bool {
+	return engine.elapsed < s.exhaustedUntil
+}
+
+// SpeedFactor returns the part of the speed left by exhaustion.
+func (s *Stamina) SpeedFactor() float64 {
+	if s.Exhausted() {
+		return 1 - s.ExhaustSlow
+	}
+	return 1
+}
+
+// DrawStamina draws a thin bar below the hero while stamina is not full.
+func (h *Hero) DrawStamina(imd *imdraw.IMDraw) {
+	s := &h.Stamina
+	if s.Max <= 0 || s.Value >= s.Max {
+		return
+	}
+	const w = 10.0
+	min := h.Pos.Add(pixel.V(-w/2, -10))
+	imd.Color = colornames.Black
+	imd.Push(min, min.Add(pixel.V(w, 1)))
+	imd.Rectangle(0)
+	imd.Color = colornames.Gold
+	if s.Exhausted() {
+		imd.Color = colornames.Crimson
+	}
+	imd.Push(min, min.Add(pixel.V(w*s.Value/s.Max, 1)))
+	imd.Rectangle(0)
+}
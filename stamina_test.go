@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestStaminaRegenAfterSpend(t *testing.T) {
+	engine = &Engine{}
+	s := NewStamina(defaultTuning.Stamina)
+	s.Value = 50
+
+	// Charging while standing still drains and regenerates in the same frames.
+	engine.dt = 1.0 / 60
+	for i := 0; i < 60; i++ {
+		engine.elapsed += engine.dt
+		s.Drain(s.Charge)
+		s.Regenerate()
+	}
+	if s.Value >= 50 {
+		t.Fatalf("stamina grew to %v while charging", s.Value)
+	}
+
+	charged := s.Value
+	for i := 0; i < 60; i++ {
+		engine.elapsed += engine.dt
+		s.Regenerate()
+	}
+	if s.Value <= charged {
+		t.Fatalf("stamina did not come back a second after charging, still %v", s.Value)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
+	"golang.org/x/image/colornames"
+)
+
+type StatusKind uint8
+
+const (
+	// StatusSlow takes Strength part of the speed.
+	StatusSlow StatusKind = iota
+	// StatusBurn deals Strength damage per second.
+	StatusBurn
+	// StatusStun stops all movement.
+	StatusStun
+	// StatusPoison deals Strength damage per second for every stack.
+	StatusPoison
+	// StatusKnockback pushes with a velocity which fades out.
+	StatusKnockback
+	numberOfStatusKinds
+)
+
+// Stacking defines what happens when an effect is applied while the same
+// effect is still active.
+type Stacking uint8
+
+const (
+	// StackStrongest keeps the stronger and the longer of both.
+	StackStrongest Stacking = iota
+	// StackAdd adds a stack up to MaxStacks and restarts the duration.
+	StackAdd
+	// StackReplace drops the old effect.
+	StackReplace
+)
+
+type StatusRule struct {
+	Stacking  Stacking
+	MaxStacks int
+	Tint      color.RGBA // zero tint keeps the color of the sprite
+}
+
+var statusRules = [numberOfStatusKinds]StatusRule{
+	StatusSlow:      {Stacking: StackStrongest, MaxStacks: 1, Tint: colornames.Lightblue},
+	StatusBurn:      {Stacking: StackStrongest, MaxStacks: 1, Tint: colornames.Orange},
+	StatusStun:      {Stacking: StackStrongest, MaxStacks: 1, Tint: colornames.Lightskyblue},
+	StatusPoison:    {Stacking: StackAdd, MaxStacks: 5, Tint: colornames.Limegreen},
+	StatusKnockback: {Stacking: StackReplace, MaxStacks: 1},
+}
+
+type StatusEffect struct {
+	Strength float64
+	Stacks   int
+	Vel      pixel.Vec // velocity of the knockback
+	Start    float64
+	Until    float64
+}
+
+// Statuses holds active status effects of an enemy or the hero, one per kind.
+type Statuses struct {
+	effects [numberOfStatusKinds]StatusEffect
+}
+
+// Knockback velocity loses this part of itself every second.
+const knockbackDrag = 6.0
+
+// Knockback lasts until its velocity is too small to matter.
+const knockbackTime = 1.0
+
+func (s *Statuses) Clear() {
+	s.effects = [numberOfStatusKinds]StatusEffect{}
+}
+
+func (s *Statuses) Active(kind StatusKind) bool {
+	return engine.elapsed < s.effects[kind].Until
+}
+
+// Apply starts the effect with strength for duration seconds following the
+// stacking rule of the kind.
+func (s *Statuses) Apply(kind StatusKind, strength, duration float64) {
+	e := &s.effects[kind]
+	until := engine.elapsed + duration
+	if !s.Active(kind) {
+		*e = StatusEffect{Strength: strength, Stacks: 1, Start: engine.elapsed, Until: until}
+		return
+	}
+	rule := statusRules[kind]
+	switch rule.Stacking {
+	case StackStrongest:
+		e.Strength = math.Max(e.Strength, strength)
+		if until > e.Until {
+			e.Until = until
+			e.Start = engine.elapsed
+		}
+	case StackAdd:
+		if e.Stacks < rule.MaxStacks {
+			e.Stacks++
+		}
+		e.Strength = math.Max(e.Strength, strength)
+		e.Start = engine.elapsed
+		e.Until = until
+	default:
+		*e = StatusEffect{Strength: strength, Stacks: 1, Start: engine.elapsed, Until: until}
+	}
+}
+
+// Knock pushes with velocity vel.
+func (s *Statuses) Knock(vel pixel.Vec) {
+	s.Apply(StatusKnockback, 0, knockbackTime)
+	s.effects[StatusKnockback].Vel = vel
+}
+
+// SpeedFactor returns the part of the normal speed left by slow and stun.
+func (s *Statuses) SpeedFactor() float64 {
+	if s.Active(StatusStun) {
+		return 0
+	}
+	if s.Active(StatusSlow) {
+		return pixel.Clamp(1-s.effects[StatusSlow].Strength, 0, 1)
+	}
+	return 1
+}
+
+// Tick advances the effects by one frame. It returns the damage dealt
+// during the frame and the velocity of the knockback.
+func (s *Statuses) Tick() (damage float64, push pixel.Vec) {
+	if s.Active(StatusBurn) {
+		damage += s.effects[StatusBurn].Strength * engine.dt
+	}
+	if s.Active(StatusPoison) {
+		p := &s.effects[StatusPoison]
+		damage += p.Strength * float64(p.Stacks) * engine.dt
+	}
+	if s.Active(StatusKnockback) {
+		k := &s.effects[StatusKnockback]
+		push = k.Vel
+		k.Vel = k.Vel.Scaled(math.Max(0, 1-knockbackDrag*engine.dt))
+	}
+	return damage, push
+}
+
+// Tint mixes base with the colors of active effects. Stun hides the others.
+func (s *Statuses) Tint(base color.RGBA) color.RGBA {
+	if s.Active(StatusStun) {
+		return statusRules[StatusStun].Tint
+	}
+	c := base
+	for kind, rule := range statusRules {
+		if rule.Tint.A == 0 || !s.Active(StatusKind(kind)) {
+			continue
+		}
+		c = mix(c, rule.Tint, 0.5)
+	}
+	return c
+}
+
+// remaining returns the part of the duration of the effect which is left.
+func (s *Statuses) remaining(kind StatusKind) float64 {
+	e := &s.effects[kind]
+	if !s.Active(kind) || e.Until <= e.Start {
+		return 0
+	}
+	return (e.Until - engine.elapsed) / (e.Until - e.Start)
+}
+
+func mix(a, b color.RGBA, t float64) color.RGBA {
+	lerp := func(x, y uint8) uint8 {
+		return uint8(float64(x) + (float64(y)-float64(x))*t)
+	}
+	return color.RGBA{lerp(a.R, b.R), lerp(a.G, b.G), lerp(a.B, b.B), lerp(a.A, b.A)}
+}
+
+// DrawStatusIcons draws icons of active effects in a row starting at pos
+// with the remaining time below every icon and a pip for every stack.
+// Kinds without an icon are skipped.
+func DrawStatusIcons(t pixel.Target, imd *imdraw.IMDraw, pos pixel.Vec, s *Statuses, icons []*pixel.Sprite) {
+	for kind, icon := range icons {
+		k := StatusKind(kind)
+		if icon == nil || !s.Active(k) {
+			continue
+		}
+		pos = drawTimerIcon(t, imd, pos, icon, statusRules[k].Tint, s.remaining(k), s.effects[k].Stacks)
+	}
+}
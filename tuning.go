@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Tuning holds the numbers which balance the game.
+type Tuning struct {
+	Hero        HeroTuning         `json:"hero"`
+	Slime       SlimeTuning        `json:"slime"`
+	Slimeling   SlimeVariantTuning `json:"slimeling"`
+	BigSlime    SlimeVariantTuning `json:"bigSlime"`
+	Spitter     EnemyTuning        `json:"spitter"`
+	Beetle      EnemyTuning        `json:"beetle"`
+	Necromancer EnemyTuning        `json:"necromancer"`
+	Boss        BossTuning         `json:"boss"`
+	Arrow       ArrowTuning        `json:"arrow"`
+	Quiver      QuiverTuning       `json:"quiver"`
+	Combo       ComboTuning        `json:"combo"`
+	Ability     AbilityTuning      `json:"ability"`
+	// Stats of every hero class by its ID.
+	Classes ClassTable  `json:"classes"`
+	Lives   LivesTuning `json:"lives"`
+	// Stamina of the hero.
+	Stamina StaminaTuning `json:"stamina"`
+	Pickups PickupRules   `json:"pickups"`
+	// Enemies do not spawn closer to the hero than this. It must stay below
+	// half the shorter side of the world, or nothing could spawn.
+	SpawnExclusion float64 `json:"spawnExclusion"`
+	// Contact of every enemy kind, kinds left out drain.
+	Contact ContactTable `json:"contact"`
+}
+
+type HeroTuning struct {
+	MaxVel float64 `json:"maxVel"`
+	Accel  float64 `json:"accel"`
+	Health float64 `json:"health"`
+	// Seconds of invulnerability after a hit.
+	Invulnerability float64 `json:"invulnerability"`
+	// Draining enemies slow the touching hero down to this part of its speed.
+	DrainSlow    float64    `json:"drainSlow"`
+	BashCooldown float64    `json:"bashCooldown"` // seconds between two bow bashes
+	Dash         DashTuning `json:"dash"`
+}
+
+type DashTuning struct {
+	Speed    float64 `json:"speed"`
+	Time     float64 `json:"time"`     // seconds of the burst
+	Cooldown float64 `json:"cooldown"` // seconds from the start of one dash to the next one
+	Immunity float64 `json:"immunity"` // seconds of drain immunity from the start of the dash
+}
+
+type SlimeTuning struct {
+	// A spawned slime gets BaseSpeed, a random part of up to 1.2 * Speed and
+	// Speedup for every second of the run.
+	BaseSpeed float64 `json:"baseSpeed"`
+	Speed     float64 `json:"speed"`
+	Speedup   float64 `json:"speedup"`
+	Drain     float64 `json:"drain"` // health per second taken from the touched hero
+}
+
+// SlimeVariantTuning tunes a kind of slime relative to the plain one.
+type SlimeVariantTuning struct {
+	SpeedFactor float64 `json:"speedFactor"` // multiplies the speed of the slime
+	Drain       float64 `json:"drain"`
+}
+
+// EnemyTuning tunes the enemy kinds which are not slimes. A spawned enemy gets
+// Speed and Speedup for every second of the run.
+type EnemyTuning struct {
+	Speed   float64 `json:"speed"`
+	Speedup float64 `json:"speedup"`
+	Drain   float64 `json:"drain"`
+}
+
+type BossTuning struct {
+	Health      float64 `json:"health"`
+	Speed       float64 `json:"speed"`
+	Drain       float64 `json:"drain"`
+	ChargeSpeed float64 `json:"chargeSpeed"`
+	Windup      float64 `json:"windup"`     // seconds of shaking before a charge
+	ChargeTime  float64 `json:"chargeTime"` // seconds of the charge
+	// In endless mode the slime king comes every Interval seconds.
+	Interval float64 `json:"interval"`
+}
+
+type ArrowTuning struct {
+	Speed     float64 `json:"speed"`
+	MaxHeight float64 `json:"maxHeight"` // of the flight curve
+	DrawTime  float64 `json:"drawTime"`  // seconds to draw an arrow from the quiver
+	// Holding the shot charges the arrow in ChargeTime seconds, the fully
+	// charged arrow deals ChargeDamage times more damage.
+	ChargeTime   float64 `json:"chargeTime"`
+	ChargeDamage float64 `json:"chargeDamage"`
+	// Holding the shot longer than ChargeDelay seconds starts charging, so a
+	// quick click shoots a normal arrow. The fully charged arrow flies
+	// ChargeSpeed part faster.
+	ChargeDelay float64 `json:"chargeDelay"`
+	ChargeSpeed float64 `json:"chargeSpeed"`
+	// Chance for an arrow to break when it hits something.
+	BreakChance float64 `json:"breakChance"`
+	// Ricocheting arrows look for the next enemy within this distance.
+	RicochetRange float64 `json:"ricochetRange"`
+}
+
+type QuiverTuning struct {
+	MaxCapacity int `json:"maxCapacity"`
+	// Without upgrades the quiver grows by one arrow every UpgradeScore points.
+	UpgradeScore int `json:"upgradeScore"`
+}
+
+type ComboTuning struct {
+	Window   float64 `json:"window"` // seconds between kills to keep the chain going
+	MaxChain int     `json:"maxChain"`
+}
+
+type LivesTuning struct {
+	// Dead hero spins and shrinks for DeathTime seconds before it respawns.
+	DeathTime float64 `json:"deathTime"`
+	// Respawned hero can not be hurt by touch for Immunity seconds.
+	Immunity float64 `json:"immunity"`
+	// Enemies within this distance from the respawn spot vanish.
+	ClearRadius float64 `json:"clearRadius"`
+}
+
+// AbilityTuning holds the numbers of the class abilities.
+type AbilityTuning struct {
+	StompRadius    float64 `json:"stompRadius"`
+	StompKnockback float64 `json:"stompKnockback"`
+	StompStun      float64 `json:"stompStun"` // seconds
+	BlinkRange     float64 `json:"blinkRange"`
+	// Bulwark heals BulwarkHeal part of the max health and shields the hero
+	// for BulwarkTime seconds.
+	BulwarkHeal float64 `json:"bulwarkHeal"`
+	BulwarkTime float64 `json:"bulwarkTime"`
+}
+
+var defaultTuning = Tuning{
+	Hero: HeroTuning{
+		MaxVel:          90,
+		Accel:           400,
+		Health:          100,
+		Invulnerability: 0.8,
+		DrainSlow:       0.6,
+		BashCooldown:    0.8,
+		Dash:            DashTuning{Speed: 260, Time: 0.18, Cooldown: 0.9, Immunity: 0.3},
+	},
+	Slime:       SlimeTuning{BaseSpeed: 30, Speed: 40, Speedup: 0.2, Drain: 120},
+	Slimeling:   SlimeVariantTuning{SpeedFactor: 1.3, Drain: 60},
+	BigSlime:    SlimeVariantTuning{SpeedFactor: 0.6, Drain: 160},
+	Spitter:     EnemyTuning{Speed: 35, Drain: 40},
+	Beetle:      EnemyTuning{Speed: 32, Speedup: 0.125, Drain: 150},
+	Necromancer: EnemyTuning{Speed: 28, Drain: 40},
+	Boss: BossTuning{
+		Health:      30,
+		Speed:       22,
+		Drain:       200,
+		ChargeSpeed: 210,
+		Windup:      0.8,
+		ChargeTime:  0.9,
+		Interval:    180,
+	},
+	Arrow: ArrowTuning{
+		Speed:         150,
+		MaxHeight:     100,
+		DrawTime:      1.0,
+		ChargeTime:    1.0,
+		ChargeDamage:  2,
+		ChargeDelay:   0.15,
+		ChargeSpeed:   0.3,
+		BreakChance:   0.1,
+		RicochetRange: 64,
+	},
+	Quiver: QuiverTuning{MaxCapacity: 12, UpgradeScore: 2500},
+	Combo:  ComboTuning{Window: 1.5, MaxChain: 8},
+	Ability: AbilityTuning{
+		StompRadius:    40,
+		StompKnockback: 260,
+		StompStun:      1.5,
+		BlinkRange:     80,
+		BulwarkHeal:    0.3,
+		BulwarkTime:    3,
+	},
+	Classes: ClassTable{
+		"ranger":    {Speed: 1.15, Health: 1, Dash: 1, Cooldown: 15},
+		"heavy":     {Speed: 0.8, Health: 1.4, Dash: 1.3, Pierce: 1, Cooldown: 10},
+		"trickster": {Speed: 1, Health: 0.9, Dash: 0.6, Ricochet: 1, Cooldown: 6},
+		"swift":     {Speed: 1.3, Health: 0.75, Dash: 0.75, Cooldown: 8},
+		"sturdy":    {Speed: 0.85, Health: 1.5, Dash: 1.2, Cooldown: 12},
+	},
+	Lives: LivesTuning{DeathTime: 1.5, Immunity: 2.5, ClearRadius: 64},
+	Stamina: StaminaTuning{
+		Max:         100,
+		Regen:       35,
+		RegenDelay:  0.5,
+		Sprint:      30,
+		SprintSpeed: 1.5,
+		Dash:        25,
+		Charge:      20,
+		ExhaustTime: 1.5,
+		ExhaustSlow: 0.4,
+	},
+	Pickups:        PickupRules{Lifetime: 12, Blink: 3, Every: 20, DropChance: 0.04, PointEvery: 35},
+	SpawnExclusion: 102,
+	Contact: ContactTable{
+		EnemyBigSlime: {Model: ContactHit, Damage: 20, Knockback: 180},
+		EnemyBeetle:   {Model: ContactHit, Damage: 25, Knockback: 220},
+		EnemyBoss:     {Model: ContactHit, Damage: 30, Knockback: 260},
+	},
+}
+
+// tuning is in effect for the current run.
+var tuning = defaultTuning
+
+// TuningFile is a tuning with partial overrides per game mode.
+type TuningFile struct {
+	Tuning
+	Modes map[string]json.RawMessage `json:"modes"`
+}
+
+// Game modes which may override the tuning.
+const (
+	ModeWaves   = "waves"
+	ModeEndless = "endless"
+)
+
+// LoadTuning reads the tuning at path, missing numbers keep their defaults.
+// The override of mode and then level, both partial tunings, are applied on
+// top. An empty path stands for the defaults.
+func LoadTuning(path, mode string, level json.RawMessage) (Tuning, error) {
+	f := TuningFile{Tuning: defaultTuning}
+	// Overrides write into the maps, the defaults must stay as they are.
+	f.Contact = make(ContactTable)
+	for k, c := range defaultTuning.Contact {
+		f.Contact[k] = c
+	}
+	f.Classes = make(ClassTable)
+	for id, c := range defaultTuning.Classes {
+		f.Classes[id] = c
+	}
+	if path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return f.Tuning, err
+		}
+		defer file.Close()
+		dec := json.NewDecoder(file)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&f); err != nil {
+			return f.Tuning, fmt.Errorf("%s: %v", path, err)
+		}
+		for m := range f.Modes {
+			if m != ModeWaves && m != ModeEndless {
+				return f.Tuning, fmt.Errorf("%s: unknown mode %q", path, m)
+			}
+		}
+	}
+	t := f.Tuning
+	if err := t.override(f.Modes[mode]); err != nil {
+		return t, fmt.Errorf("%s: mode %s: %v", path, mode, err)
+	}
+	if err := t.override(level); err != nil {
+		return t, fmt.Errorf("level tuning: %v", err)
+	}
+	if err := t.validate(); err != nil {
+		return t, fmt.Errorf("%s: %v", path, err)
+	}
+	return t, nil
+}
+
+// override decodes the partial tuning data over t.
+func (t *Tuning) override(data json.RawMessage) error {
+	if len(data) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(t)
+}
+
+func (t *Tuning) validate() error {
+	positive := []struct {
+		name  string
+		value float64
+	}{
+		{"hero.maxVel", t.Hero.MaxVel},
+		{"hero.accel", t.Hero.Accel},
+		{"hero.health", t.Hero.Health},
+		{"hero.dash.speed", t.Hero.Dash.Speed},
+		{"hero.dash.time", t.Hero.Dash.Time},
+		{"slime.speed", t.Slime.Speed},
+		{"slimeling.speedFactor", t.Slimeling.SpeedFactor},
+		{"bigSlime.speedFactor", t.BigSlime.SpeedFactor},
+		{"spitter.speed", t.Spitter.Speed},
+		{"beetle.speed", t.Beetle.Speed},
+		{"necromancer.speed", t.Necromancer.Speed},
+		{"boss.health", t.Boss.Health},
+		{"boss.speed", t.Boss.Speed},
+		{"boss.interval", t.Boss.Interval},
+		{"arrow.speed", t.Arrow.Speed},
+		{"arrow.drawTime", t.Arrow.DrawTime},
+		{"arrow.chargeTime", t.Arrow.ChargeTime},
+		{"combo.window", t.Combo.Window},
+		{"lives.deathTime", t.Lives.DeathTime},
+		{"stamina.max", t.Stamina.Max},
+		{"pickups.lifetime", t.Pickups.Lifetime},
+	}
+	for _, p := range positive {
+		if p.value <= 0 {
+			return fmt.Errorf("%s must be positive", p.name)
+		}
+	}
+	nonNegative := []struct {
+		name  string
+		value float64
+	}{
+		{"hero.invulnerability", t.Hero.Invulnerability},
+		{"hero.bashCooldown", t.Hero.BashCooldown},
+		{"hero.dash.cooldown", t.Hero.Dash.Cooldown},
+		{"hero.dash.immunity", t.Hero.Dash.Immunity},
+		{"slime.baseSpeed", t.Slime.BaseSpeed},
+		{"slime.speedup", t.Slime.Speedup},
+		{"slime.drain", t.Slime.Drain},
+		{"slimeling.drain", t.Slimeling.Drain},
+		{"bigSlime.drain", t.BigSlime.Drain},
+		{"spitter.speedup", t.Spitter.Speedup},
+		{"spitter.drain", t.Spitter.Drain},
+		{"beetle.speedup", t.Beetle.Speedup},
+		{"beetle.drain", t.Beetle.Drain},
+		{"necromancer.speedup", t.Necromancer.Speedup},
+		{"necromancer.drain", t.Necromancer.Drain},
+		{"boss.drain", t.Boss.Drain},
+		{"boss.chargeSpeed", t.Boss.ChargeSpeed},
+		{"boss.windup", t.Boss.Windup},
+		{"boss.chargeTime", t.Boss.ChargeTime},
+		{"arrow.maxHeight", t.Arrow.MaxHeight},
+		{"arrow.chargeDelay", t.Arrow.ChargeDelay},
+		{"arrow.chargeSpeed", t.Arrow.ChargeSpeed},
+		{"arrow.ricochetRange", t.Arrow.RicochetRange},
+		{"ability.stompRadius", t.Ability.StompRadius},
+		{"ability.stompKnockback", t.Ability.StompKnockback},
+		{"ability.stompStun", t.Ability.StompStun},
+		{"ability.blinkRange", t.Ability.BlinkRange},
+		{"ability.bulwarkTime", t.Ability.BulwarkTime},
+		{"pickups.blink", t.Pickups.Blink},
+		{"pickups.every", t.Pickups.Every},
+		{"pickups.pointEvery", t.Pickups.PointEvery},
+		{"lives.immunity", t.Lives.Immunity},
+		{"lives.clearRadius", t.Lives.ClearRadius},
+		{"spawnExclusion", t.SpawnExclusion},
+	}
+	for _, p := range nonNegative {
+		if p.value < 0 {
+			return fmt.Errorf("%s must not be negative", p.name)
+		}
+	}
+	parts := []struct {
+		name  string
+		value float64
+	}{
+		{"hero.drainSlow", t.Hero.DrainSlow},
+		{"ability.bulwarkHeal", t.Ability.BulwarkHeal},
+		{"arrow.breakChance", t.Arrow.BreakChance},
+		{"pickups.dropChance", t.Pickups.DropChance},
+	}
+	for _, p := range parts {
+		if p.value < 0 || p.value > 1 {
+			return fmt.Errorf("%s must be within [0, 1]", p.name)
+		}
+	}
+	if t.Quiver.MaxCapacity < 1 || t.Quiver.UpgradeScore < 1 || t.Combo.MaxChain < 1 {
+		return fmt.Errorf("quiver.maxCapacity, quiver.upgradeScore and combo.maxChain must be at least 1")
+	}
+	if t.Arrow.ChargeDamage < 1 || t.Stamina.SprintSpeed < 1 {
+		return fmt.Errorf("arrow.chargeDamage and stamina.sprintSpeed must be at least 1")
+	}
+	s := &t.Stamina
+	if s.Regen < 0 || s.RegenDelay < 0 || s.Sprint < 0 || s.Dash < 0 || s.Charge < 0 || s.ExhaustTime < 0 || s.ExhaustSlow < 0 || s.ExhaustSlow > 1 {
+		return fmt.Errorf("stamina costs must not be negative and exhaustSlow must be within [0, 1]")
+	}
+	for i := range heroClasses {
+		id := heroClasses[i].ID
+		s, ok := t.Classes[id]
+		if !ok {
+			return fmt.Errorf("no stats of class %s", id)
+		}
+		if err := s.validate(); err != nil {
+			return fmt.Errorf("class %s: %v", id, err)
+		}
+	}
+	for kind, c := range t.Contact {
+		if err := c.validate(); err != nil {
+			return fmt.Errorf("contact of %s: %v", kind, err)
+		}
+	}
+	return nil
+}
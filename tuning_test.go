@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadTuningPartialContact(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tuning")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tuning.json")
+	file := `{
+		"contact": {"beetle": {"knockback": 300}},
+		"modes": {"waves": {"contact": {"bigslime": {"damage": 5}}}}
+	}`
+	if err := ioutil.WriteFile(path, []byte(file), 0644); err != nil {
+		t.Fatal(err)
+	}
+	level := json.RawMessage(`{"contact": {"boss": {"model": "drain"}, "slime": {"model": "hit", "damage": 10}}}`)
+
+	got, err := LoadTuning(path, ModeWaves, level)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[EnemyKind]Contact{
+		EnemySlime:    {Model: ContactHit, Damage: 10},
+		EnemyBigSlime: {Model: ContactHit, Damage: 5, Knockback: 180},
+		EnemyBeetle:   {Model: ContactHit, Damage: 25, Knockback: 300},
+		EnemyBoss:     {Model: ContactDrain, Damage: 30, Knockback: 260},
+	}
+	for kind, c := range want {
+		if got.Contact[kind] != c {
+			t.Errorf("contact of %s = %+v, want %+v", kind, got.Contact[kind], c)
+		}
+	}
+	if len(got.Contact) != len(want) {
+		t.Errorf("got %d contacts, want %d", len(got.Contact), len(want))
+	}
+	if c := defaultTuning.Contact[EnemyBeetle]; c.Knockback != 220 {
+		t.Errorf("defaults changed by the override: beetle %+v", c)
+	}
+
+	if _, err := LoadTuning("", ModeEndless, json.RawMessage(`{"contact": {"beetle": {"knock": 1}}}`)); err == nil {
+		t.Error("unknown contact field accepted")
+	}
+	if _, err := LoadTuning("", ModeEndless, json.RawMessage(`{"contact": {"dragon": {}}}`)); err == nil {
+		t.Error("unknown enemy kind accepted")
+	}
+}
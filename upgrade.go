@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+)
+
+// UpgradeEffect is what an upgrade does to the hero or the quiver.
+type UpgradeEffect uint8
+
+const (
+	// UpgradeCapacity adds Amount arrows to the quiver, up to tuning.Quiver.MaxCapacity.
+	UpgradeCapacity UpgradeEffect = iota
+	// UpgradeDrawTime multiplies the time to draw an arrow by Amount.
+	UpgradeDrawTime
+	// UpgradePierce lets every arrow go through Amount more enemies.
+	UpgradePierce
+	// UpgradeMaxHealth adds Amount to the maximum health and heals as much.
+	UpgradeMaxHealth
+	// UpgradeReturn brings arrows stuck in the ground back to the quiver
+	// after Amount seconds.
+	UpgradeReturn
+	// UpgradeDash multiplies the cooldown of the dash by Amount.
+	UpgradeDash
+)
+
+var upgradeEffectNames = [...]string{
+	UpgradeCapacity:  "capacity",
+	UpgradeDrawTime:  "drawtime",
+	UpgradePierce:    "pierce",
+	UpgradeMaxHealth: "maxhealth",
+	UpgradeReturn:    "return",
+	UpgradeDash:      "dash",
+}
+
+func (e *UpgradeEffect) UnmarshalText(text []byte) error {
+	for i, name := range upgradeEffectNames {
+		if name == string(text) {
+			*e = UpgradeEffect(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown upgrade effect %q", text)
+}
+
+type Upgrade struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Effect      UpgradeEffect `json:"effect"`
+	Amount      float64       `json:"amount"`
+	Max         int           `json:"max"` // how many times the upgrade may be taken, 0 for no limit
+}
+
+type UpgradePool struct {
+	Upgrades []Upgrade `json:"upgrades"`
+	// In endless mode the choice is offered every Score points.
+	Score int `json:"score"`
+}
+
+func LoadUpgradePool(path string) (*UpgradePool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	p := &UpgradePool{}
+	dec := json.NewDecoder(file)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(p); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := p.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return p, nil
+}
+
+func (p *UpgradePool) validate() error {
+	if len(p.Upgrades) < upgradeChoices {
+		return fmt.Errorf("at least %d upgrades are needed", upgradeChoices)
+	}
+	if p.Score <= 0 {
+		return fmt.Errorf("score must be positive")
+	}
+	for i, u := range p.Upgrades {
+		if u.Name == "" || u.Max < 0 || u.Amount <= 0 {
+			return fmt.Errorf("upgrade %d: name is required, amount must be positive and max not negative", i+1)
+		}
+		if (u.Effect == UpgradeDrawTime || u.Effect == UpgradeDash) && u.Amount >= 1 {
+			return fmt.Errorf("upgrade %d: %s multiplier must be below 1", i+1, upgradeEffectNames[u.Effect])
+		}
+	}
+	return nil
+}
+
+// The hero chooses one of this many upgrades.
+const upgradeChoices = 3
+
+// UpgradeChoice offers random upgrades from the pool and remembers the
+// chosen ones.
+type UpgradeChoice struct {
+	pool   *UpgradePool
+	taken  []int // how many times every upgrade was chosen
+	order  []int // chosen upgrades in order
+	Offer  []int // indices of the offered upgrades, empty when nothing is offered
+	Active bool
+}
+
+func NewUpgradeChoice(pool *UpgradePool) *UpgradeChoice {
+	return &UpgradeChoice{pool: pool, taken: make([]int, len(pool.Upgrades))}
+}
+
+// Roll offers up to upgradeChoices random upgrades which may still be taken
+// and still do something for the quiver. It returns false when there is
+// nothing left to offer.
+func (c *UpgradeChoice) Roll(q *Quiver) bool {
+	var open []int
+	for i, u := range c.pool.Upgrades {
+		if (u.Max == 0 || c.taken[i] < u.Max) && u.Available(q) {
+			open = append(open, i)
+		}
+	}
+	rand.Shuffle(len(open), func(i, j int) {
+		open[i], open[j] = open[j], open[i]
+	})
+	if len(open) > upgradeChoices {
+		open = open[:upgradeChoices]
+	}
+	c.Offer = open
+	c.Active = len(open) > 0
+	return c.Active
+}
+
+// Choose takes the i-th offered upgrade and returns it, or nil if there is
+// no such offer.
+func (c *UpgradeChoice) Choose(i int) *Upgrade {
+	if !c.Active || i < 0 || i >= len(c.Offer) {
+		return nil
+	}
+	idx := c.Offer[i]
+	c.taken[idx]++
+	c.order = append(c.order, idx)
+	c.Offer = c.Offer[:0]
+	c.Active = false
+	return &c.pool.Upgrades[idx]
+}
+
+// Describe returns the offered upgrades as numbered lines.
+func (c *UpgradeChoice) Describe() string {
+	var b strings.Builder
+	b.WriteString("Choose an upgrade\n\n")
+	for i, idx := range c.Offer {
+		u := &c.pool.Upgrades[idx]
+		fmt.Fprintf(&b, "%d: %s - %s\n", i+1, u.Name, u.Description)
+	}
+	return b.String()
+}
+
+// Summary lists the chosen upgrades in order of choice, repeats are counted.
+func (c *UpgradeChoice) Summary() string {
+	var names []string
+	seen := make([]bool, len(c.taken))
+	for _, idx := range c.order {
+		if seen[idx] {
+			continue
+		}
+		seen[idx] = true
+		name := c.pool.Upgrades[idx].Name
+		if c.taken[idx] > 1 {
+			name += fmt.Sprintf(" x%d", c.taken[idx])
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
+
+// Available returns false when the upgrade would do nothing, like a bigger
+// quiver when the quiver is as big as it gets.
+func (u *Upgrade) Available(q *Quiver) bool {
+	return u.Effect != UpgradeCapacity || q.Capacity < tuning.Quiver.MaxCapacity
+}
+
+// Apply gives the upgrade to the hero and the quiver.
+func (u *Upgrade) Apply(q *Quiver) {
+	switch u.Effect {
+	case UpgradeCapacity:
+		n := int(u.Amount)
+		if q.Capacity+n > tuning.Quiver.MaxCapacity {
+			n = tuning.Quiver.MaxCapacity - q.Capacity
+		}
+		if n > 0 {
+			q.Upgrade(n, ArrowWooden)
+		}
+	case UpgradeDrawTime:
+		q.DrawTime *= u.Amount
+	case UpgradePierce:
+		q.PierceBonus += int(u.Amount)
+	case UpgradeMaxHealth:
+		hero.maxHealth += u.Amount
+		hero.Damage(u.Amount)
+	case UpgradeReturn:
+		if q.ReturnTime == 0 || u.Amount < q.ReturnTime {
+			q.ReturnTime = u.Amount
+		}
+	case UpgradeDash:
+		hero.DashCooldown *= u.Amount
+	}
+}
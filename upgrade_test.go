@@ -0,0 +1,87 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/faiface/pixel"
+)
+
+// setupGlobals gives tests the engine clock and a hero without a window.
+func setupGlobals() {
+	engine = &Engine{}
+	hero = NewHero(nil, pixel.ZV, 1, 1)
+}
+
+func testPool() *UpgradePool {
+	return &UpgradePool{
+		Score: 100,
+		Upgrades: []Upgrade{
+			{Name: "Bigger quiver", Effect: UpgradeCapacity, Amount: 2, Max: 9},
+			{Name: "Quick draw", Effect: UpgradeDrawTime, Amount: 0.5, Max: 1},
+			{Name: "Piercing shots", Effect: UpgradePierce, Amount: 1},
+			{Name: "Homing quiver", Effect: UpgradeReturn, Amount: 6, Max: 1},
+		},
+	}
+}
+
+func offered(c *UpgradeChoice, name string) bool {
+	for _, idx := range c.Offer {
+		if c.pool.Upgrades[idx].Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestUpgradeCapacityLimit(t *testing.T) {
+	setupGlobals()
+	tests := []struct {
+		capacity, want int
+	}{
+		{3, 5},
+		{tuning.Quiver.MaxCapacity - 1, tuning.Quiver.MaxCapacity},
+		{tuning.Quiver.MaxCapacity, tuning.Quiver.MaxCapacity},
+	}
+	u := &testPool().Upgrades[0]
+	for _, tt := range tests {
+		q := NewQuiver(nil, nil, tt.capacity, 1, 0)
+		u.Apply(q)
+		if q.Capacity != tt.want || q.Stock() != tt.want {
+			t.Errorf("capacity %d upgraded to %d with %d arrows, want %d", tt.capacity, q.Capacity, q.Stock(), tt.want)
+		}
+	}
+}
+
+func TestUpgradeRoll(t *testing.T) {
+	setupGlobals()
+	c := NewUpgradeChoice(testPool())
+	q := NewQuiver(nil, nil, tuning.Quiver.MaxCapacity, 1, 0)
+	for i := 0; i < 20; i++ {
+		if !c.Roll(q) {
+			t.Fatal("nothing offered")
+		}
+		if offered(c, "Bigger quiver") {
+			t.Fatal("bigger quiver offered for the full quiver")
+		}
+	}
+
+	// Upgrades taken Max times are not offered any more.
+	for c.Roll(q) {
+		if !offered(c, "Quick draw") {
+			continue
+		}
+		for i, idx := range c.Offer {
+			if c.pool.Upgrades[idx].Name == "Quick draw" {
+				c.Choose(i)
+				break
+			}
+		}
+		break
+	}
+	for i := 0; i < 20; i++ {
+		c.Roll(q)
+		if offered(c, "Quick draw") {
+			t.Fatal("quick draw offered after it was taken its max times")
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+
+	"github.com/faiface/pixel"
+)
+
+// SpawnPattern defines where enemies of a group appear.
+type SpawnPattern uint8
+
+const (
+	// SpawnRandom places enemies anywhere far enough from the hero.
+	SpawnRandom SpawnPattern = iota
+	// SpawnRing places enemies evenly on a circle around the hero, the parts
+	// of the circle beyond the walls go to the edges instead.
+	SpawnRing
+	// SpawnEdge places enemies along the outer walls.
+	SpawnEdge
+	// SpawnCluster places enemies close to each other at one random spot.
+	SpawnCluster
+)
+
+var spawnPatternNames = [...]string{
+	SpawnRandom:  "random",
+	SpawnRing:    "ring",
+	SpawnEdge:    "edge",
+	SpawnCluster: "cluster",
+}
+
+func (p *SpawnPattern) UnmarshalText(text []byte) error {
+	for i, name := range spawnPatternNames {
+		if name == string(text) {
+			*p = SpawnPattern(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown spawn pattern %q", text)
+}
+
+// WaveGroup is a bunch of enemies of one kind spawned by the same pattern.
+type WaveGroup struct {
+	Enemy    EnemyKind    `json:"enemy"`
+	Count    int          `json:"count"`
+	Pattern  SpawnPattern `json:"pattern"`
+	Delay    float64      `json:"delay"`    // seconds from the start of the wave to the first spawn
+	Interval float64      `json:"interval"` // seconds between spawns of the group
+	Radius   float64      `json:"radius"`   // radius of the ring or the cluster
+	// Movement overrides the default movement of the enemy kind.
+	Movement *Movement `json:"movement"`
+}
+
+type Wave struct {
+	Name   string      `json:"name"`
+	Groups []WaveGroup `json:"groups"`
+	// Timeout starts the next wave even if enemies of this one are still alive.
+	Timeout float64 `json:"timeout"`
+	// Boss brings the slime king at the start of the wave.
+	Boss bool `json:"boss"`
+}
+
+// WaveScaling makes the waves harder every time the whole list is repeated.
+type WaveScaling struct {
+	Count    float64 `json:"count"`    // count multiplier per loop
+	Interval float64 `json:"interval"` // interval and delay multiplier per loop
+	Repeat   int     `json:"repeat"`   // index of the wave to restart from after the last one
+}
+
+type WaveSet struct {
+	Pause   float64     `json:"pause"` // seconds of rest between waves
+	Waves   []Wave      `json:"waves"`
+	Scaling WaveScaling `json:"scaling"`
+	// Difficulty overrides the default bounds of the adaptive difficulty.
+	Difficulty *DifficultyBounds `json:"difficulty"`
+	// Tuning is a partial tuning applied over the tuning file.
+	Tuning json.RawMessage `json:"tuning"`
+}
+
+func LoadWaveSet(path string) (*WaveSet, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	ws := &WaveSet{}
+	dec := json.NewDecoder(file)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(ws); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := ws.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return ws, nil
+}
+
+func (ws *WaveSet) validate() error {
+	if len(ws.Waves) == 0 {
+		return fmt.Errorf("no waves defined")
+	}
+	if ws.Pause < 0 {
+		return fmt.Errorf("negative pause")
+	}
+	for i, w := range ws.Waves {
+		if len(w.Groups) == 0 && !w.Boss {
+			return fmt.Errorf("wave %d: no groups", i+1)
+		}
+		for j, g := range w.Groups {
+			if g.Count <= 0 || g.Delay < 0 || g.Interval < 0 || g.Radius < 0 {
+				return fmt.Errorf("wave %d, group %d: count must be positive, delay, interval and radius not negative", i+1, j+1)
+			}
+			if g.Movement != nil {
+				if g.Enemy == EnemyBoss {
+					return fmt.Errorf("wave %d, group %d: the boss does not follow movement patterns", i+1, j+1)
+				}
+				if err := g.Movement.validate(); err != nil {
+					return fmt.Errorf("wave %d, group %d: %v", i+1, j+1, err)
+				}
+			}
+		}
+	}
+	sc := &ws.Scaling
+	if sc.Count == 0 {
+		sc.Count = 1
+	}
+	if sc.Interval == 0 {
+		sc.Interval = 1
+	}
+	if sc.Count < 0 || sc.Interval < 0 {
+		return fmt.Errorf("negative scaling")
+	}
+	if sc.Repeat < 0 || sc.Repeat >= len(ws.Waves) {
+		return fmt.Errorf("scaling: repeat must point to one of %d waves", len(ws.Waves))
+	}
+	if ws.Difficulty != nil {
+		return ws.Difficulty.validate()
+	}
+	return nil
+}
+
+// WaveDirector runs the waves of a WaveSet one after another.
+type WaveDirector struct {
+	set   *WaveSet
+	horde *Horde
+
+	Number  int // number of the current wave counting from 1, repeats included
+	index   int // index of the current wave in the set
+	loop    int // how many times the set was repeated
+	started float64
+	resting bool
+	restEnd float64
+	cleared bool // the wave has just been cleared
+
+	spawned []int // spawned enemies per group of the current wave
+	anchors []pixel.Vec
+	// The spawn rate and the toughness of the difficulty when the current
+	// wave started, so the schedule of the wave does not shift under it.
+	spawnRate float64
+	toughness float64
+
+	announce    string
+	announceEnd float64
+}
+
+func NewWaveDirector(set *WaveSet, horde *Horde) *WaveDirector {
+	d := &WaveDirector{set: set, horde: horde, index: -1}
+	d.rest()
+	return d
+}
+
+func (d *WaveDirector) rest() {
+	d.resting = true
+	d.restEnd = engine.elapsed + d.set.Pause
+}
+
+func (d *WaveDirector) next() {
+	d.index++
+	if d.index >= len(d.set.Waves) {
+		d.index = d.set.Scaling.Repeat
+		d.loop++
+	}
+	d.Number++
+	d.resting = false
+	d.started = engine.elapsed
+	d.spawnRate = difficulty.SpawnRate()
+	d.toughness = difficulty.Toughness()
+	w := &d.set.Waves[d.index]
+	d.spawned = make([]int, len(w.Groups))
+	d.anchors = make([]pixel.Vec, len(w.Groups))
+	for i := range d.anchors {
+		d.anchors[i] = farSpot()
+	}
+	d.announce = fmt.Sprintf("Wave %d", d.Number)
+	if w.Name != "" {
+		d.announce += ": " + w.Name
+	}
+	if w.Boss {
+		d.horde.Spawn(EnemyBoss)
+		d.announce += "\nThe Slime King is coming!"
+	}
+	d.announceEnd = engine.elapsed + 3
+}
+
+// count returns the number of enemies of the group for the current loop.
+// Groups of enemies other than slimes scale with the toughness as well, but
+// keep at least one enemy.
+func (d *WaveDirector) count(g *WaveGroup) int {
+	n := float64(g.Count) * math.Pow(d.set.Scaling.Count, float64(d.loop))
+	if g.Enemy == EnemySlime || g.Enemy == EnemySlimeling {
+		return int(math.Round(n))
+	}
+	return int(math.Max(1, math.Round(n*d.toughness)))
+}
+
+// time scales delays and intervals for the current loop and the difficulty.
+func (d *WaveDirector) time(t float64) float64 {
+	return t * math.Pow(d.set.Scaling.Interval, float64(d.loop)) / d.spawnRate
+}
+
+func (d *WaveDirector) Update() {
+	if d.resting {
+		if engine.elapsed >= d.restEnd {
+			d.next()
+		}
+		return
+	}
+
+	w := &d.set.Waves[d.index]
+	t := engine.elapsed - d.started
+	done := true
+	for i := range w.Groups {
+		g := &w.Groups[i]
+		n := d.count(g)
+		for d.spawned[i] < n && t >= d.time(g.Delay+g.Interval*float64(d.spawned[i])) {
+			e := d.horde.SpawnAt(g.Enemy, d.spot(i, g, d.spawned[i], n))
+			if m, ok := e.(Mover); ok && g.Movement != nil {
+				m.SetMovement(*g.Movement)
+			}
+			d.spawned[i]++
+		}
+		if d.spawned[i] < n {
+			done = false
+		}
+	}
+
+	if !done {
+		return
+	}
+	if d.horde.AliveCount() == 0 || (w.Timeout > 0 && t > w.Timeout) {
+		d.rest()
+		d.cleared = true
+	}
+}
+
+// spot returns the position of the i-th out of n enemies of the group.
+func (d *WaveDirector) spot(group int, g *WaveGroup, i, n int) pixel.Vec {
+	switch g.Pattern {
+	case SpawnRing:
+		r := g.Radius
+		if r == 0 {
+			r = tuning.SpawnExclusion
+		}
+		angle := 2 * math.Pi * float64(i) / float64(n)
+		p := world.ClampVec(hero.Pos.Add(pixel.Unit(angle).Scaled(r)))
+		// Near a wall the clamped spot comes closer to the hero than the
+		// ring, possibly right on top of it.
+		if hero.Pos.Sub(p).Len() < r-1 {
+			return d.edgeSpot()
+		}
+		return p
+	case SpawnEdge:
+		return d.edgeSpot()
+	case SpawnCluster:
+		r := g.Radius
+		if r == 0 {
+			r = 16
+		}
+		offset := pixel.Unit(rand.Float64() * 2 * math.Pi).Scaled(rand.Float64() * r)
+		return world.ClampVec(d.anchors[group].Add(offset))
+	default:
+		return farSpot()
+	}
+}
+
+func (d *WaveDirector) edgeSpot() pixel.Vec {
+	r := world.Interior()
+	return furthestSpot(func() pixel.Vec {
+		switch rand.Intn(4) {
+		case 0:
+			return pixel.V(r.Min.X, r.Min.Y+rand.Float64()*r.H())
+		case 1:
+			return pixel.V(r.Max.X, r.Min.Y+rand.Float64()*r.H())
+		case 2:
+			return pixel.V(r.Min.X+rand.Float64()*r.W(), r.Min.Y)
+		default:
+			return pixel.V(r.Min.X+rand.Float64()*r.W(), r.Max.Y)
+		}
+	})
+}
+
+// Cleared returns true once after every finished wave.
+func (d *WaveDirector) Cleared() bool {
+	c := d.cleared
+	d.cleared = false
+	return c
+}
+
+// Announcement returns the text about the wave which has just started, if any.
+func (d *WaveDirector) Announcement() string {
+	if engine.elapsed > d.announceEnd {
+		return ""
+	}
+	return d.announce
+}
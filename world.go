@@ -144,6 +144,34 @@ func (w *World) RandomVec() pixel.Vec {
 	)
 }
 
+// Interior returns the area where RandomVec places things, away from the outer walls.
+func (w *World) Interior() pixel.Rect {
+	return pixel.R(
+		float64(w.gridSize),
+		float64(w.gridSize),
+		float64((w.width-2)*w.gridSize),
+		float64((w.height-2)*w.gridSize),
+	)
+}
+
+// ClampVec moves v inside the interior of the world.
+func (w *World) ClampVec(v pixel.Vec) pixel.Vec {
+	r := w.Interior()
+	return pixel.V(pixel.Clamp(v.X, r.Min.X, r.Max.X), pixel.Clamp(v.Y, r.Min.Y, r.Max.Y))
+}
+
+// Fit moves v so that the collider placed at v stays clear of the outer
+// walls. Colliders touching a wall count as stuck, hence the extra pixel.
+func (w *World) Fit(v pixel.Vec, collider pixel.Rect) pixel.Vec {
+	edge := float64(w.gridSize)/2 + 1
+	min := pixel.V(edge, edge).Sub(collider.Min)
+	max := pixel.V(
+		float64((w.width-1)*w.gridSize)-edge,
+		float64((w.height-1)*w.gridSize)-edge,
+	).Sub(collider.Max)
+	return pixel.V(pixel.Clamp(v.X, min.X, max.X), pixel.Clamp(v.Y, min.Y, max.Y))
+}
+
 func (w *World) GetColliders(collider pixel.Rect) []pixel.Rect {
 	x1 := w.spaceToGrid(collider.Min.X)
 	y1 := w.spaceToGrid(collider.Min.Y)